@@ -1,9 +1,11 @@
 package tfimage
 
 import (
+	"image"
+	"io"
+	"io/fs"
 	"io/ioutil"
-
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"time"
 )
 
 //input_1 (InputLayer)         (None, 224, 224, 3)       0
@@ -14,62 +16,122 @@ import (
 // https://github.com/idealo/image-quality-assessment/
 // Apache 2.0 License
 type AestheticsEvaluator struct {
-	graph   *tf.Graph
-	session *tf.Session
+	model AestheticsModel
+	stats ModelStats
+}
+
+// Input and output operations of the NIMA models
+const (
+	nimaInput  = "input_1"
+	nimaOutput = "dense_1/Softmax"
+)
+
+// AestheticsOptions - Options of an AestheticsEvaluator
+type AestheticsOptions struct {
+	ModelOptions
 }
 
 // NewAestheticsEvaluator - Creates a new Aesthetics Evaluator
 func NewAestheticsEvaluator(modelFile string) (*AestheticsEvaluator, error) {
-	eval := &AestheticsEvaluator{}
+	return NewAestheticsEvaluatorWithOptions(modelFile, AestheticsOptions{})
+}
 
+// NewAestheticsEvaluatorWithOptions - Creates a new Aesthetics Evaluator from a model file with options
+func NewAestheticsEvaluatorWithOptions(modelFile string, options AestheticsOptions) (*AestheticsEvaluator, error) {
 	model, err := ioutil.ReadFile(modelFile)
 	if err != nil {
 		return nil, err
 	}
+	return NewAestheticsEvaluatorFromBytes(model, options)
+}
+
+// NewAestheticsEvaluatorFromBytes - Creates a new Aesthetics Evaluator from a serialized model
+func NewAestheticsEvaluatorFromBytes(model []byte, options AestheticsOptions) (*AestheticsEvaluator, error) {
+	start := time.Now()
+	m, err := options.backend().LoadAestheticsModel(model, options.ModelOptions)
+	if err != nil {
+		return nil, err
+	}
+	return NewAestheticsEvaluatorFromAestheticsModel(m).loaded(start, options.Warmup)
+}
+
+// NewAestheticsEvaluatorFromReader - Creates a new Aesthetics Evaluator from a reader of a serialized model
+func NewAestheticsEvaluatorFromReader(r io.Reader, options AestheticsOptions) (*AestheticsEvaluator, error) {
+	model, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewAestheticsEvaluatorFromBytes(model, options)
+}
+
+// NewAestheticsEvaluatorFromFS - Creates a new Aesthetics Evaluator from a model file in fsys, such as an embed.FS
+func NewAestheticsEvaluatorFromFS(fsys fs.FS, name string, options AestheticsOptions) (*AestheticsEvaluator, error) {
+	model, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return NewAestheticsEvaluatorFromBytes(model, options)
+}
 
-	eval.graph = tf.NewGraph()
-	if err := eval.graph.Import(model, ""); err != nil {
+// NewAestheticsEvaluatorFromRegistry - Creates a new Aesthetics Evaluator from the NIMA model name of a Registry.
+// The checksum of the model file is verified, and the Preprocess of the entry is attached if set.
+func NewAestheticsEvaluatorFromRegistry(reg *Registry, name string, options AestheticsOptions) (*AestheticsEvaluator, error) {
+	def, entry, err := reg.Load(name, NIMAModel)
+	if err != nil {
 		return nil, err
 	}
+	options.Signature = entry.Signature
+	eval, err := NewAestheticsEvaluatorFromBytes(def, options)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Preprocess != nil {
+		if err = eval.SetPreprocess(*entry.Preprocess); err != nil {
+			eval.Close()
+			return nil, err
+		}
+	}
+	return eval, nil
+}
 
-	eval.session, err = tf.NewSession(eval.graph, nil)
+// NewAestheticsEvaluatorFromAestheticsModel - Creates a new Aesthetics Evaluator from an
+// AestheticsModel loaded by a Backend. The AestheticsEvaluator takes ownership of the model.
+func NewAestheticsEvaluatorFromAestheticsModel(model AestheticsModel) *AestheticsEvaluator {
+	return &AestheticsEvaluator{model: model}
+}
+
+// loaded records the load time of the AestheticsEvaluator since start and runs the warm-up
+func (eval *AestheticsEvaluator) loaded(start time.Time, warmup WarmupOptions) (*AestheticsEvaluator, error) {
+	eval.stats.LoadTime = time.Since(start)
+	err := warmup.warmup(&eval.stats, func(img *ImageData) error {
+		_, err := eval.model.Scores(img)
+		return err
+	})
 	if err != nil {
+		eval.Close()
 		return nil, err
 	}
 	return eval, nil
 }
 
+// Stats returns the load and warm-up timings of the AestheticsEvaluator
+func (eval *AestheticsEvaluator) Stats() ModelStats {
+	return eval.stats
+}
+
 // Close closes the Aesthetics Evaluator's Session
 func (eval *AestheticsEvaluator) Close() {
-	if eval.session != nil {
-		eval.session.Close()
-		eval.graph = nil
-		eval.session = nil
-	}
+	eval.model.Close()
 }
 
-func (eval *AestheticsEvaluator) Run(tensor *tf.Tensor) (score float32, err error) {
-
-	graph := eval.graph
-	output, err := eval.session.Run(
-		map[tf.Output]*tf.Tensor{
-			graph.Operation("input_1").Output(0): tensor,
-		},
-		[]tf.Output{
-			graph.Operation("dense_1/Softmax").Output(0),
-		},
-		nil,
-	)
+// RunImage evaluates the aesthetics of an image, returning a score from 1 to 10.
+// The image is fed at its own size; NIMA models expect 224x224 images.
+func (eval *AestheticsEvaluator) RunImage(img image.Image) (score float32, err error) {
+	values, err := eval.model.Scores(NewImageData(img))
 	if err != nil {
 		return 0, err
 	}
-
-	if len(output) > 0 {
-		values := output[0].Value().([][]float32)[0]
-		score = float32(calcScore(values))
-		//fmt.Println(output[0].Shape())
-	}
-	return score, nil
+	return calcScore(values), nil
 }
 
 func calcScore(values []float32) (sum float32) {
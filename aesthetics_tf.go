@@ -0,0 +1,91 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"fmt"
+	"time"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// NIMASignature returns the Signature of the NIMA MobileNet models
+func NIMASignature() Signature {
+	return Signature{
+		Inputs:  map[string]string{ImageInput: nimaInput},
+		Outputs: map[string]string{"scores": nimaOutput},
+		Specs: map[string]TensorSpec{
+			ImageInput: {DType: tf.Float, Rank: 4},
+			"scores":   {DType: tf.Float, Rank: 2},
+		},
+	}
+}
+
+// NewAestheticsEvaluatorFromSavedModel - Creates a new Aesthetics Evaluator from a SavedModel directory.
+// The inputs and outputs of the NIMASignature are resolved from options.SavedModel.
+func NewAestheticsEvaluatorFromSavedModel(exportDir string, options AestheticsOptions) (*AestheticsEvaluator, error) {
+	start := time.Now()
+	m, err := NewModelFromSavedModel(exportDir, NIMASignature(), options.ModelOptions)
+	if err != nil {
+		return nil, err
+	}
+	return NewAestheticsEvaluatorFromModel(m).loaded(start, options.Warmup)
+}
+
+// NewAestheticsEvaluatorFromModel - Creates a new Aesthetics Evaluator from a Model with a NIMASignature.
+// The AestheticsEvaluator takes ownership of the Model.
+func NewAestheticsEvaluatorFromModel(model *Model) *AestheticsEvaluator {
+	return NewAestheticsEvaluatorFromAestheticsModel(&tfAestheticsModel{model: model})
+}
+
+// SetPreprocess attaches a Preprocess that is applied to image tensors before evaluation.
+// It is supported by the TensorflowBackend.
+func (eval *AestheticsEvaluator) SetPreprocess(p Preprocess) error {
+	m, ok := eval.model.(*tfAestheticsModel)
+	if !ok {
+		return fmt.Errorf("preprocess is not supported by the backend of the aesthetics evaluator")
+	}
+	return m.model.SetPreprocess(p)
+}
+
+func (eval *AestheticsEvaluator) Run(tensor *tf.Tensor) (score float32, err error) {
+	var values []float32
+	if m, ok := eval.model.(*tfAestheticsModel); ok {
+		values, err = m.scores(tensor)
+	} else {
+		var img *ImageData
+		if img, err = imageDataFromTensor(tensor); err == nil {
+			values, err = eval.model.Scores(img)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return calcScore(values), nil
+}
+
+// tfAestheticsModel is a NIMA Model of the TensorflowBackend
+type tfAestheticsModel struct {
+	model *Model
+}
+
+func (m *tfAestheticsModel) Scores(img *ImageData) ([]float32, error) {
+	tensor, err := img.tensor()
+	if err != nil {
+		return nil, err
+	}
+	return m.scores(tensor)
+}
+
+func (m *tfAestheticsModel) scores(tensor *tf.Tensor) ([]float32, error) {
+	output, err := m.model.Run(map[string]*tf.Tensor{ImageInput: tensor}, "scores")
+	if err != nil {
+		return nil, err
+	}
+	return output["scores"].Value().([][]float32)[0], nil
+}
+
+func (m *tfAestheticsModel) Close() {
+	m.model.Close()
+}
@@ -0,0 +1,96 @@
+package tfimage
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Backend - An inference engine that loads and runs the models of a
+// FaceDetector and an AestheticsEvaluator.
+//
+// TensorflowBackend runs the models with libtensorflow through cgo and is the
+// default. GoBackend runs them in pure Go, and is the default when the package
+// is built without cgo or with the "notensorflow" build tag.
+type Backend interface {
+	// LoadFaceModel loads a serialized MTCNN model
+	LoadFaceModel(def []byte, options ModelOptions) (FaceModel, error)
+	// LoadAestheticsModel loads a serialized NIMA model
+	LoadAestheticsModel(def []byte, options ModelOptions) (AestheticsModel, error)
+}
+
+// FaceModel - An MTCNN model loaded by a Backend
+type FaceModel interface {
+	// DetectFaces runs the MTCNN cascade on img
+	DetectFaces(img *ImageData, params MTCNNParams) ([]Face, error)
+	Close()
+}
+
+// CascadeBackend - A Backend that can run the P-Net, R-Net and O-Net of an
+// MTCNN model as separate graphs, driving the cascade from Go.
+type CascadeBackend interface {
+	Backend
+	// LoadCascadeFaceModel loads a serialized MTCNN model in cascade mode
+	LoadCascadeFaceModel(def []byte, options ModelOptions) (CascadeFaceModel, error)
+}
+
+// CascadeFaceModel - An MTCNN model that runs the stages of the cascade separately
+type CascadeFaceModel interface {
+	FaceModel
+	// DetectStages runs the MTCNN cascade on img and also returns the candidates of each stage
+	DetectStages(img *ImageData, params MTCNNParams) ([]Face, []MTCNNStage, error)
+}
+
+// AestheticsModel - A NIMA model loaded by a Backend
+type AestheticsModel interface {
+	// Scores returns the probabilities of the aesthetic scores 1 to 10 of img
+	Scores(img *ImageData) ([]float32, error)
+	Close()
+}
+
+// MTCNNParams - Parameters of the MTCNN cascade
+type MTCNNParams struct {
+	// MinSize is the size in pixels of the smallest face detected
+	MinSize float32
+	// Factor is the scale factor between the levels of the image pyramid
+	Factor float32
+	// Thresholds are the minimum scores of the P-Net, R-Net and O-Net stages
+	Thresholds [3]float32
+}
+
+// ImageData - An RGB image as float32 values in height, width, channel order.
+// Values are in the 0-255 range of the source image.
+type ImageData struct {
+	Width, Height int
+	Pix           []float32
+}
+
+// NewImageData converts an image to ImageData
+func NewImageData(img image.Image) *ImageData {
+	b := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	}
+
+	data := &ImageData{Width: b.Dx(), Height: b.Dy(), Pix: make([]float32, b.Dx()*b.Dy()*3)}
+	for y := 0; y < data.Height; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x := 0; x < data.Width; x++ {
+			i := (y*data.Width + x) * 3
+			data.Pix[i] = float32(row[x*4])
+			data.Pix[i+1] = float32(row[x*4+1])
+			data.Pix[i+2] = float32(row[x*4+2])
+		}
+	}
+	return data
+}
+
+// check validates the size of the ImageData
+func (img *ImageData) check() error {
+	if img.Width <= 0 || img.Height <= 0 || len(img.Pix) != img.Width*img.Height*3 {
+		return fmt.Errorf("image data %dx%d has %d values, expected %d", img.Width, img.Height, len(img.Pix), img.Width*img.Height*3)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !cgo || notensorflow
+// +build !cgo notensorflow
+
+package tfimage
+
+// defaultBackend is the GoBackend when the package is built without libtensorflow
+func defaultBackend() Backend {
+	return GoBackend
+}
@@ -0,0 +1,64 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// TensorflowBackend runs models with libtensorflow through cgo. It is the default Backend.
+var TensorflowBackend Backend = tensorflowBackend{}
+
+// defaultBackend is the TensorflowBackend when the package is built with libtensorflow
+func defaultBackend() Backend {
+	return TensorflowBackend
+}
+
+type tensorflowBackend struct{}
+
+func (tensorflowBackend) LoadFaceModel(def []byte, options ModelOptions) (FaceModel, error) {
+	sig, err := MTCNNSignature().withNames(options.Signature)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newModel(def, sig, options)
+	if err != nil {
+		return nil, err
+	}
+	return &tfFaceModel{model: m}, nil
+}
+
+func (tensorflowBackend) LoadAestheticsModel(def []byte, options ModelOptions) (AestheticsModel, error) {
+	sig, err := NIMASignature().withNames(options.Signature)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newModel(def, sig, options)
+	if err != nil {
+		return nil, err
+	}
+	return &tfAestheticsModel{model: m}, nil
+}
+
+// tensor converts the ImageData to a [1,H,W,3] float32 tensor
+func (img *ImageData) tensor() (*tf.Tensor, error) {
+	if err := img.check(); err != nil {
+		return nil, err
+	}
+	t := &goTensor{shape: []int{1, img.Height, img.Width, 3}, data: img.Pix}
+	return t.tfTensor()
+}
+
+// imageDataFromTensor converts a [1,H,W,3] or [H,W,3] image tensor to ImageData
+func imageDataFromTensor(tensor *tf.Tensor) (*ImageData, error) {
+	data, h, w, c, err := tensorPixels(tensor)
+	if err != nil {
+		return nil, err
+	}
+	img := &ImageData{Width: w, Height: h, Pix: data}
+	if c != 3 {
+		return nil, img.check()
+	}
+	return img, nil
+}
@@ -27,25 +27,19 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	// Reduce image to 2000px max edge
-
-	start := time.Now()
-	tfImg, err := tfimage.TensorFromJpeg(buf)
+	srcImage, err := jpeg.Decode(bytes.NewReader(buf))
 	if err != nil {
-		fmt.Println(err)
+		panic(err)
 	}
-	fmt.Println("Time to Tensor: ", time.Since(start))
 
-	faceResults, err := det.DetectFaces(tfImg)
+	start := time.Now()
+	faceResults, err := det.DetectImage(srcImage)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Println(faceResults)
+	fmt.Println("Time to detect faces: ", time.Since(start))
 
-	srcImage, err := jpeg.Decode(bytes.NewReader(buf))
-	if err != nil {
-		panic(err)
-	}
 	start = time.Now()
 	n := 0
 	faceResults.ToJPEG(srcImage, draw.CatmullRom, 256, 256, func(faceImage image.Image) error {
@@ -55,12 +49,7 @@ func main() {
 	fmt.Println("Time taken to save images to disk:", time.Since(start))
 	faceResults.DrawDebugJPEG("debug.jpg", srcImage)
 
-	var buf2 bytes.Buffer
-	srcImage = imaging.Resize(srcImage, 800, 600, imaging.CatmullRom)
-	err = jpeg.Encode(&buf2, srcImage, &jpeg.Options{Quality: 90})
-	if err != nil {
-		panic(err)
-	}
+	aeImage := imaging.Resize(srcImage, 800, 600, imaging.CatmullRom)
 	// Aesthetics
 	eval, err := tfimage.NewAestheticsEvaluator("../models/nima_1.14.pb")
 	if err != nil {
@@ -68,12 +57,8 @@ func main() {
 	}
 
 	defer eval.Close()
-	aeImg, err := tfimage.TensorFromJpeg(buf2.Bytes())
-	if err != nil {
-		fmt.Println(err)
-	}
 
 	start = time.Now()
-	fmt.Println(eval.Run(aeImg))
+	fmt.Println(eval.RunImage(aeImage))
 	fmt.Println("Time to calculate visual asthetic of image: ", time.Since(start))
 }
@@ -0,0 +1,89 @@
+package tfimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// BorderMode - How Crop handles crop rectangles that extend past the image
+type BorderMode uint8
+
+// Border modes
+const (
+	// BorderClamp shrinks the crop rectangle to the image
+	BorderClamp BorderMode = iota
+	// BorderFill pads the crop with the Fill color
+	BorderFill
+	// BorderReplicate pads the crop by repeating the pixels at the edges of the image
+	BorderReplicate
+)
+
+// CropOptions - Options of Face.Crop
+type CropOptions struct {
+	// Margin is the fraction of the width and height of the box added on each side
+	Margin float64
+	// Square extends the shorter side of the crop to the longer side
+	Square bool
+	Border BorderMode
+	// Fill is the padding color of BorderFill, defaults to transparent
+	Fill color.Color
+}
+
+// Crop returns the region of the box of the face in src, without alignment,
+// and the rectangle of the crop in the coordinates of src.
+func (f Face) Crop(src image.Image, opts CropOptions) (*image.RGBA, image.Rectangle) {
+	x1, y1, x2, y2 := float64(f.box[1]), float64(f.box[0]), float64(f.box[3]), float64(f.box[2])
+	w, h := x2-x1, y2-y1
+	if opts.Square {
+		cx, cy, side := (x1+x2)/2, (y1+y2)/2, math.Max(w, h)
+		x1, y1, x2, y2 = cx-side/2, cy-side/2, cx+side/2, cy+side/2
+		w, h = side, side
+	}
+	x1, x2 = x1-w*opts.Margin, x2+w*opts.Margin
+	y1, y2 = y1-h*opts.Margin, y2+h*opts.Margin
+	r := image.Rect(int(math.Floor(x1)), int(math.Floor(y1)), int(math.Ceil(x2)), int(math.Ceil(y2)))
+
+	b := src.Bounds()
+	if opts.Border == BorderClamp {
+		r = r.Intersect(b)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	if opts.Border == BorderFill && opts.Fill != nil {
+		draw.Draw(dst, dst.Rect, image.NewUniform(opts.Fill), image.Point{}, draw.Src)
+	}
+	inner := r.Intersect(b)
+	if inner.Empty() {
+		return dst, r
+	}
+	inner = inner.Sub(r.Min)
+	draw.Draw(dst, inner, src, inner.Min.Add(r.Min), draw.Src)
+	if opts.Border == BorderReplicate {
+		replicateBorder(dst, inner)
+	}
+	return dst, r
+}
+
+// replicateBorder fills the pixels of img outside of inner with the nearest pixel of inner
+func replicateBorder(img *image.RGBA, inner image.Rectangle) {
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		row := img.Pix[y*img.Stride : (y+1)*img.Stride]
+		left, right := row[inner.Min.X*4:inner.Min.X*4+4], row[(inner.Max.X-1)*4:inner.Max.X*4]
+		for x := 0; x < inner.Min.X; x++ {
+			copy(row[x*4:], left)
+		}
+		for x := inner.Max.X; x < img.Rect.Max.X; x++ {
+			copy(row[x*4:], right)
+		}
+	}
+	first := img.Pix[inner.Min.Y*img.Stride : (inner.Min.Y+1)*img.Stride]
+	for y := 0; y < inner.Min.Y; y++ {
+		copy(img.Pix[y*img.Stride:], first)
+	}
+	last := img.Pix[(inner.Max.Y-1)*img.Stride : inner.Max.Y*img.Stride]
+	for y := inner.Max.Y; y < img.Rect.Max.Y; y++ {
+		copy(img.Pix[y*img.Stride:], last)
+	}
+}
@@ -0,0 +1,101 @@
+package tfimage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// cropTestImage returns a 100x80 image with the coordinates of each pixel in its red and green values
+func cropTestImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			img.SetRGBA(x, y, cropTestColor(x, y))
+		}
+	}
+	return img
+}
+
+func cropTestColor(x, y int) color.RGBA {
+	return color.RGBA{uint8(x), uint8(y), 7, 255}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func TestFaceCrop(t *testing.T) {
+	fill := color.RGBA{0, 0, 255, 255}
+	tests := []struct {
+		name string
+		box  [4]float32 // y1, x1, y2, x2
+		opts CropOptions
+		want image.Rectangle
+	}{
+		{"inside", [4]float32{20, 30, 60, 70}, CropOptions{}, image.Rect(30, 20, 70, 60)},
+		{"fractional box", [4]float32{20.5, 10.5, 30.2, 20.2}, CropOptions{}, image.Rect(10, 20, 21, 31)},
+		{"left edge clamp", [4]float32{20, -10, 60, 30}, CropOptions{Border: BorderClamp}, image.Rect(0, 20, 30, 60)},
+		{"right edge fill", [4]float32{10, 80, 40, 110}, CropOptions{Border: BorderFill, Fill: fill}, image.Rect(80, 10, 110, 40)},
+		{"top edge replicate", [4]float32{-15, 30, 25, 60}, CropOptions{Border: BorderReplicate}, image.Rect(30, -15, 60, 25)},
+		{"bottom edge margin replicate", [4]float32{70, 40, 90, 60}, CropOptions{Margin: 0.25, Border: BorderReplicate}, image.Rect(35, 65, 65, 95)},
+		{"top right corner square clamp", [4]float32{-5, 90, 35, 110}, CropOptions{Square: true, Border: BorderClamp}, image.Rect(80, 0, 100, 35)},
+		{"bottom left corner transparent fill", [4]float32{70, -5, 85, 15}, CropOptions{Border: BorderFill}, image.Rect(-5, 70, 15, 85)},
+		{"corners replicate", [4]float32{-10, -10, 90, 110}, CropOptions{Border: BorderReplicate}, image.Rect(-10, -10, 110, 90)},
+		{"outside fill", [4]float32{10, 200, 20, 210}, CropOptions{Border: BorderFill, Fill: fill}, image.Rect(200, 10, 210, 20)},
+	}
+	src := cropTestImage()
+	for _, test := range tests {
+		f := NewFace(0.9, test.box, [10]float32{})
+		img, r := f.Crop(src, test.opts)
+		if r != test.want {
+			t.Errorf("%s: rectangle %v, want %v", test.name, r, test.want)
+			continue
+		}
+		if b := img.Bounds(); b != image.Rect(0, 0, r.Dx(), r.Dy()) {
+			t.Errorf("%s: bounds %v, want the size of %v", test.name, b, r)
+			continue
+		}
+		for y := 0; y < r.Dy(); y++ {
+			for x := 0; x < r.Dx(); x++ {
+				sx, sy := r.Min.X+x, r.Min.Y+y
+				var want color.RGBA
+				switch {
+				case image.Pt(sx, sy).In(src.Rect):
+					want = cropTestColor(sx, sy)
+				case test.opts.Border == BorderFill && test.opts.Fill != nil:
+					want = fill
+				case test.opts.Border == BorderReplicate:
+					want = cropTestColor(clampInt(sx, 0, 99), clampInt(sy, 0, 79))
+				}
+				if c := img.RGBAAt(x, y); c != want {
+					t.Fatalf("%s: pixel (%d,%d) of source (%d,%d) %v, want %v", test.name, x, y, sx, sy, c, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFaceCropSubImage(t *testing.T) {
+	// the box is in the coordinates of the source
+	src := cropTestImage().SubImage(image.Rect(20, 10, 60, 50))
+	f := NewFace(0.9, [4]float32{5, 50, 25, 70}, [10]float32{})
+	img, r := f.Crop(src, CropOptions{Border: BorderReplicate})
+	if r != image.Rect(50, 5, 70, 25) {
+		t.Fatalf("rectangle %v, want (50,5)-(70,25)", r)
+	}
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			want := cropTestColor(clampInt(r.Min.X+x, 20, 59), clampInt(r.Min.Y+y, 10, 49))
+			if c := img.RGBAAt(x, y); c != want {
+				t.Fatalf("pixel (%d,%d) %v, want %v", x, y, c, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package tfimage
+
+import "image"
+
+// Detector - Detects faces in images. It is implemented by FaceDetector, and by
+// the fakes of the tfimagetest package.
+type Detector interface {
+	DetectImage(img image.Image) (*FaceResults, error)
+	Close()
+}
+
+// AestheticScorer - Scores the aesthetics of images from 1 to 10. It is implemented by
+// AestheticsEvaluator and ReloadableAestheticsEvaluator, and by the fakes of the
+// tfimagetest package.
+type AestheticScorer interface {
+	RunImage(img image.Image) (score float32, err error)
+	Close()
+}
+
+var (
+	_ Detector        = (*FaceDetector)(nil)
+	_ AestheticScorer = (*AestheticsEvaluator)(nil)
+	_ AestheticScorer = (*ReloadableAestheticsEvaluator)(nil)
+)
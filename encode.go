@@ -0,0 +1,311 @@
+package tfimage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// Encoder - Encodes images to a writer
+type Encoder interface {
+	Encode(w io.Writer, img image.Image) error
+}
+
+// Metadata - ICC profile and EXIF data written with an encoded image.
+// EXIF is the TIFF structured payload, without the "Exif\x00\x00" header of JPEG.
+// The EXIF orientation is written as is, so it should be removed from the
+// metadata of crops and rendered images that are already upright.
+type Metadata struct {
+	ICC  []byte
+	EXIF []byte
+}
+
+// JPEGEncoder - Encodes images to JPEG with Quality between 1 and 100,
+// defaults to jpeg.DefaultQuality
+type JPEGEncoder struct {
+	Quality int
+	Metadata
+}
+
+// Encode the image to w as a JPEG
+func (e JPEGEncoder) Encode(w io.Writer, img image.Image) error {
+	quality := e.Quality
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if len(e.ICC) == 0 && len(e.EXIF) == 0 {
+		_, err := w.Write(data)
+		return err
+	}
+
+	// APP segments follow the SOI marker
+	var segs bytes.Buffer
+	if len(e.EXIF) > 0 {
+		if err := writeJPEGSegment(&segs, 0xe1, jpegEXIFHeader, e.EXIF); err != nil {
+			return err
+		}
+	}
+	if len(e.ICC) > 0 {
+		n := (len(e.ICC) + jpegICCChunk - 1) / jpegICCChunk
+		if n > 255 {
+			return fmt.Errorf("jpeg: ICC profile of %d bytes is too large", len(e.ICC))
+		}
+		for i := 0; i < n; i++ {
+			chunk := e.ICC[i*jpegICCChunk:]
+			if len(chunk) > jpegICCChunk {
+				chunk = chunk[:jpegICCChunk]
+			}
+			header := append([]byte(jpegICCHeader), byte(i+1), byte(n))
+			if err := writeJPEGSegment(&segs, 0xe2, string(header), chunk); err != nil {
+				return err
+			}
+		}
+	}
+	for _, b := range [][]byte{data[:2], segs.Bytes(), data[2:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	jpegEXIFHeader = "Exif\x00\x00"
+	jpegICCHeader  = "ICC_PROFILE\x00"
+	// jpegICCChunk is the largest ICC chunk of an APP2 segment
+	jpegICCChunk = 0xffff - 2 - len(jpegICCHeader) - 2
+)
+
+// writeJPEGSegment writes a marker segment with the payload header followed by data
+func writeJPEGSegment(w *bytes.Buffer, marker byte, header string, data []byte) error {
+	n := 2 + len(header) + len(data)
+	if n > 0xffff {
+		return fmt.Errorf("jpeg: segment of %d bytes is too large", n)
+	}
+	w.Write([]byte{0xff, marker, byte(n >> 8), byte(n)})
+	w.WriteString(header)
+	w.Write(data)
+	return nil
+}
+
+// PNGEncoder - Encodes images to lossless PNG. The zero Compression is png.DefaultCompression.
+type PNGEncoder struct {
+	Compression png.CompressionLevel
+	Metadata
+}
+
+// Encode the image to w as a PNG
+func (e PNGEncoder) Encode(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: e.Compression}
+	if err := enc.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if len(e.ICC) == 0 && len(e.EXIF) == 0 {
+		_, err := w.Write(data)
+		return err
+	}
+
+	// Ancillary chunks follow the IHDR chunk
+	var chunks bytes.Buffer
+	if len(e.ICC) > 0 {
+		var icc bytes.Buffer
+		icc.WriteString("ICC Profile\x00\x00")
+		zw := zlib.NewWriter(&icc)
+		zw.Write(e.ICC)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		writePNGChunk(&chunks, "iCCP", icc.Bytes())
+	}
+	if len(e.EXIF) > 0 {
+		writePNGChunk(&chunks, "eXIf", e.EXIF)
+	}
+	const ihdrEnd = len(pngHeader) + 8 + 13 + 4
+	for _, b := range [][]byte{data[:ihdrEnd], chunks.Bytes(), data[ihdrEnd:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+// writePNGChunk writes a chunk with its length and CRC
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	w.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	w.Write(n[:])
+}
+
+// ReadMetadata returns the ICC profile and EXIF data of a JPEG or PNG image,
+// to pass them through to encoded crops.
+func ReadMetadata(r io.Reader) (Metadata, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Metadata{}, err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return readJPEGMetadata(data)
+	case bytes.HasPrefix(data, []byte(pngHeader)):
+		return readPNGMetadata(data)
+	}
+	return Metadata{}, errors.New("metadata: unknown image format")
+}
+
+func readJPEGMetadata(data []byte) (Metadata, error) {
+	var md Metadata
+	icc := map[byte][]byte{}
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xff {
+			return md, errors.New("metadata: invalid JPEG segment")
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			i++ // fill byte
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			break // image data follows
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return md, errors.New("metadata: truncated JPEG segment")
+		}
+		seg := data[i+4 : i+2+n]
+		switch {
+		case marker == 0xe1 && md.EXIF == nil && bytes.HasPrefix(seg, []byte(jpegEXIFHeader)):
+			md.EXIF = append([]byte(nil), seg[len(jpegEXIFHeader):]...)
+		case marker == 0xe2 && len(seg) >= len(jpegICCHeader)+2 && bytes.HasPrefix(seg, []byte(jpegICCHeader)):
+			icc[seg[len(jpegICCHeader)]] = seg[len(jpegICCHeader)+2:]
+		}
+		i += 2 + n
+	}
+	seqs := make([]int, 0, len(icc))
+	for s := range icc {
+		seqs = append(seqs, int(s))
+	}
+	sort.Ints(seqs)
+	for _, s := range seqs {
+		md.ICC = append(md.ICC, icc[byte(s)]...)
+	}
+	return md, nil
+}
+
+func readPNGMetadata(data []byte) (Metadata, error) {
+	var md Metadata
+	for i := len(pngHeader); i+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		typ := string(data[i+4 : i+8])
+		if n < 0 || i+12+n > len(data) {
+			return md, errors.New("metadata: truncated PNG chunk")
+		}
+		chunk := data[i+8 : i+8+n]
+		switch typ {
+		case "iCCP":
+			// profile name, null separator and compression method precede the profile
+			j := bytes.IndexByte(chunk, 0)
+			if j < 0 || j+2 > len(chunk) {
+				return md, errors.New("metadata: invalid iCCP chunk")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(chunk[j+2:]))
+			if err != nil {
+				return md, fmt.Errorf("metadata: iCCP chunk: %v", err)
+			}
+			if md.ICC, err = ioutil.ReadAll(zr); err != nil {
+				return md, fmt.Errorf("metadata: iCCP chunk: %v", err)
+			}
+		case "eXIf":
+			md.EXIF = append([]byte(nil), chunk...)
+		case "IDAT", "IEND":
+			return md, nil
+		}
+		i += 12 + n
+	}
+	return md, nil
+}
+
+// SaveImage encodes the image to path with enc. The file is created or truncated
+// as with os.Create.
+func SaveImage(path string, img image.Image, enc Encoder) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return enc.Encode(file, img)
+}
+
+// SaveImageAtomic encodes the image to path with enc. The image is written to a
+// temporary file in the same directory which is renamed to path, so that readers
+// never see a partially written file. Unlike SaveImage, the directory must be
+// writable, the file mode is 0644 regardless of the umask, a symlink at path is
+// replaced rather than followed, and path cannot be a device such as /dev/stdout.
+func SaveImageAtomic(path string, img image.Image, enc Encoder) (err error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	if err = enc.Encode(file, img); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Chmod(0644); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// EncodeCrops encodes the aligned crop of each face in src, with the template of opts,
+// and calls fn with the crop and its encoded bytes. It stops at the first error.
+// The crop image and data are reused for every face, so fn must not keep them after returning.
+func (fr FaceResults) EncodeCrops(src image.Image, kernel draw.Interpolator, opts AlignOptions, enc Encoder, fn func(c FaceCrop, data []byte) error) error {
+	var buf bytes.Buffer
+	return fr.EachCrop(src, kernel, opts, true, func(c FaceCrop) error {
+		buf.Reset()
+		if err := enc.Encode(&buf, c.Image); err != nil {
+			return err
+		}
+		return fn(c, buf.Bytes())
+	})
+}
@@ -0,0 +1,120 @@
+package tfimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testMetadata has an ICC profile that spans several APP2 segments
+func testMetadata() Metadata {
+	icc := make([]byte, 150000)
+	for i := range icc {
+		icc[i] = byte(i * 7)
+	}
+	return Metadata{ICC: icc, EXIF: []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x00")}
+}
+
+func testEncodeImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 13)
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img
+}
+
+func TestEncodeMetadataRoundTrip(t *testing.T) {
+	md := testMetadata()
+	img := testEncodeImage()
+	for name, enc := range map[string]Encoder{
+		"jpeg": JPEGEncoder{Quality: 90, Metadata: md},
+		"png":  PNGEncoder{Compression: png.BestCompression, Metadata: md},
+	} {
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, img); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := ReadMetadata(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got.ICC, md.ICC) {
+			t.Errorf("%s: ICC profile of %d bytes, want %d", name, len(got.ICC), len(md.ICC))
+		}
+		if !bytes.Equal(got.EXIF, md.EXIF) {
+			t.Errorf("%s: EXIF %q, want %q", name, got.EXIF, md.EXIF)
+		}
+
+		// the standard decoders must accept the added segments and chunks
+		var dec image.Image
+		if name == "png" {
+			dec, err = png.Decode(&buf)
+		} else {
+			dec, err = jpeg.Decode(&buf)
+		}
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if dec.Bounds() != img.Bounds() {
+			t.Fatalf("%s: bounds %v, want %v", name, dec.Bounds(), img.Bounds())
+		}
+		if name == "png" {
+			for y := 0; y < 30; y++ {
+				for x := 0; x < 40; x++ {
+					r1, g1, b1, a1 := dec.At(x, y).RGBA()
+					r2, g2, b2, a2 := img.At(x, y).RGBA()
+					if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+						t.Fatalf("png: pixel (%d,%d) differs, the encoding is not lossless", x, y)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReadMetadataUnknownFormat(t *testing.T) {
+	if _, err := ReadMetadata(bytes.NewReader([]byte("GIF89a"))); err == nil {
+		t.Error("ReadMetadata of a GIF header returned no error")
+	}
+}
+
+// failingEncoder writes part of an image and fails
+type failingEncoder struct{}
+
+func (failingEncoder) Encode(w io.Writer, img image.Image) error {
+	w.Write([]byte("partial"))
+	return errors.New("encode failed")
+}
+
+func TestSaveImageAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "face.png")
+	if err := SaveImageAtomic(path, testEncodeImage(), PNGEncoder{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveImageAtomic(filepath.Join(dir, "missing", "face.png"), testEncodeImage(), PNGEncoder{}); err == nil {
+		t.Error("SaveImageAtomic to a missing directory returned no error")
+	}
+	if err := SaveImageAtomic(filepath.Join(dir, "failed.png"), testEncodeImage(), failingEncoder{}); err == nil {
+		t.Error("SaveImageAtomic with a failing encoder returned no error")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the directory, want 1: temporary files were left", len(entries))
+	}
+}
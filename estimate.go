@@ -0,0 +1,209 @@
+package tfimage
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Point - A 2D point used to estimate transforms
+type Point struct {
+	X, Y float64
+}
+
+// TransformModel - The kind of Matrix fitted to point correspondences
+type TransformModel uint8
+
+// Transform models
+const (
+	// Similarity is a rotation, uniform scale and translation
+	Similarity TransformModel = iota
+	// Affine is a full affine transform
+	Affine
+)
+
+// minPoints returns the number of correspondences needed to fit the model
+func (t TransformModel) minPoints() int {
+	if t == Affine {
+		return 3
+	}
+	return 2
+}
+
+// Estimate - A Matrix fitted to point correspondences
+type Estimate struct {
+	Matrix Matrix
+	// Residual is the root mean square distance between the transformed
+	// source points and the destination points of the inliers
+	Residual float64
+	// Inliers marks the correspondences used by the fit
+	Inliers []bool
+}
+
+// EstimateSimilarity fits a rotation, uniform scale and translation that maps src to dst
+// in the least squares sense. It needs at least two distinct points.
+func EstimateSimilarity(src, dst []Point) (Estimate, error) {
+	return estimate(Similarity, src, dst, nil)
+}
+
+// EstimateAffine fits an affine Matrix that maps src to dst in the least squares sense.
+// It needs at least three points that are not collinear.
+func EstimateAffine(src, dst []Point) (Estimate, error) {
+	return estimate(Affine, src, dst, nil)
+}
+
+// RANSACOptions - Options of EstimateRANSAC
+type RANSACOptions struct {
+	Model TransformModel
+	// Threshold is the distance in pixels under which a correspondence is an inlier, defaults to 3
+	Threshold float64
+	// Iterations is the number of random samples, defaults to 1000
+	Iterations int
+	// Seed seeds the random samples, so that estimates are reproducible
+	Seed int64
+}
+
+// EstimateRANSAC fits a Matrix that maps src to dst while ignoring outliers. It fits
+// the model to random minimal samples, keeps the sample with the most inliers and
+// refits the model to all of its inliers.
+func EstimateRANSAC(src, dst []Point, opts RANSACOptions) (Estimate, error) {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 3
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = 1000
+	}
+	if err := checkPoints(opts.Model, src, dst); err != nil {
+		return Estimate{}, err
+	}
+
+	n, k := len(src), opts.Model.minPoints()
+	r := rand.New(rand.NewSource(opts.Seed))
+	sample, picked := make([]bool, n), make([]int, 0, k)
+	var best []bool
+	bestCount := 0
+	for i := 0; i < opts.Iterations; i++ {
+		// draw k distinct indices
+		for _, j := range picked {
+			sample[j] = false
+		}
+		for picked = picked[:0]; len(picked) < k; {
+			if j := r.Intn(n); !sample[j] {
+				sample[j] = true
+				picked = append(picked, j)
+			}
+		}
+		e, err := estimate(opts.Model, src, dst, sample)
+		if err != nil {
+			continue // degenerate sample
+		}
+		inliers, count := make([]bool, n), 0
+		for j := range src {
+			if pointDistance(e.Matrix, src[j], dst[j]) <= opts.Threshold {
+				inliers[j] = true
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = inliers, count
+			if count == n {
+				break
+			}
+		}
+	}
+	if bestCount < k {
+		return Estimate{}, fmt.Errorf("ransac: no %d point sample has enough inliers", k)
+	}
+	return estimate(opts.Model, src, dst, best)
+}
+
+func checkPoints(model TransformModel, src, dst []Point) error {
+	if len(src) != len(dst) {
+		return fmt.Errorf("estimate: %d source points and %d destination points", len(src), len(dst))
+	}
+	if len(src) < model.minPoints() {
+		return fmt.Errorf("estimate: %d points, at least %d are needed", len(src), model.minPoints())
+	}
+	return nil
+}
+
+// estimate fits the model to the correspondences marked in use, or to all of them when use is nil
+func estimate(model TransformModel, src, dst []Point, use []bool) (Estimate, error) {
+	if err := checkPoints(model, src, dst); err != nil {
+		return Estimate{}, err
+	}
+	if use == nil {
+		use = make([]bool, len(src))
+		for i := range use {
+			use[i] = true
+		}
+	}
+
+	// Centroids
+	var sc, dc Point
+	n := 0.0
+	for i := range src {
+		if use[i] {
+			sc.X, sc.Y = sc.X+src[i].X, sc.Y+src[i].Y
+			dc.X, dc.Y = dc.X+dst[i].X, dc.Y+dst[i].Y
+			n++
+		}
+	}
+	if n < float64(model.minPoints()) {
+		return Estimate{}, fmt.Errorf("estimate: %v points, at least %d are needed", n, model.minPoints())
+	}
+	sc.X, sc.Y, dc.X, dc.Y = sc.X/n, sc.Y/n, dc.X/n, dc.Y/n
+
+	// Sums of the centered coordinates
+	var sxx, sxy, syy, xu, yu, xv, yv float64
+	for i := range src {
+		if !use[i] {
+			continue
+		}
+		x, y := src[i].X-sc.X, src[i].Y-sc.Y
+		u, v := dst[i].X-dc.X, dst[i].Y-dc.Y
+		sxx, sxy, syy = sxx+x*x, sxy+x*y, syy+y*y
+		xu, yu, xv, yv = xu+x*u, yu+y*u, xv+x*v, yv+y*v
+	}
+
+	var m Matrix
+	switch model {
+	case Similarity:
+		norm := sxx + syy
+		if norm == 0 {
+			return Estimate{}, fmt.Errorf("estimate: source points are identical")
+		}
+		a, b := (xu+yv)/norm, (xv-yu)/norm
+		m = Matrix{XX: a, YX: b, XY: -b, YY: a}
+	case Affine:
+		det := sxx*syy - sxy*sxy
+		if det <= 1e-12*(sxx+syy)*(sxx+syy) {
+			return Estimate{}, fmt.Errorf("estimate: source points are collinear")
+		}
+		m = Matrix{
+			XX: (syy*xu - sxy*yu) / det,
+			XY: (sxx*yu - sxy*xu) / det,
+			YX: (syy*xv - sxy*yv) / det,
+			YY: (sxx*yv - sxy*xv) / det,
+		}
+	default:
+		return Estimate{}, fmt.Errorf("estimate: unknown transform model %d", model)
+	}
+	m.X0 = dc.X - (m.XX*sc.X + m.XY*sc.Y)
+	m.Y0 = dc.Y - (m.YX*sc.X + m.YY*sc.Y)
+
+	var sum float64
+	for i := range src {
+		if use[i] {
+			d := pointDistance(m, src[i], dst[i])
+			sum += d * d
+		}
+	}
+	return Estimate{Matrix: m, Residual: math.Sqrt(sum / n), Inliers: use}, nil
+}
+
+// pointDistance returns the distance between src transformed by m and dst
+func pointDistance(m Matrix, src, dst Point) float64 {
+	x, y := m.TransformPoint(src.X, src.Y)
+	return math.Hypot(x-dst.X, y-dst.Y)
+}
@@ -0,0 +1,213 @@
+package tfimage
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const estimateTolerance = 1e-9
+
+// randomPoints returns n points in a 1000x1000 square
+func randomPoints(r *rand.Rand, n int) []Point {
+	pts := make([]Point, n)
+	for i := range pts {
+		pts[i] = Point{r.Float64() * 1000, r.Float64() * 1000}
+	}
+	return pts
+}
+
+// transformPoints returns the points transformed by m
+func transformPoints(m Matrix, pts []Point) []Point {
+	out := make([]Point, len(pts))
+	for i, p := range pts {
+		out[i].X, out[i].Y = m.TransformPoint(p.X, p.Y)
+	}
+	return out
+}
+
+// randomSimilarity returns a random rotation, uniform scale and translation
+func randomSimilarity(r *rand.Rand) Matrix {
+	s := 0.1 + r.Float64()*5
+	return Rotate(r.Float64()*2*math.Pi - math.Pi).
+		Multiply(Scale(s, s)).
+		Multiply(Translate(r.Float64()*2000-1000, r.Float64()*2000-1000))
+}
+
+func checkEstimate(t *testing.T, name string, e Estimate, want Matrix, inliers []bool) {
+	t.Helper()
+	if !matricesEqual(e.Matrix, want, 1e-6) {
+		t.Errorf("%s: matrix %v, want %v", name, e.Matrix, want)
+	}
+	if len(e.Inliers) != len(inliers) {
+		t.Fatalf("%s: %d inlier flags, want %d", name, len(e.Inliers), len(inliers))
+	}
+	for i := range inliers {
+		if e.Inliers[i] != inliers[i] {
+			t.Errorf("%s: point %d inlier %v, want %v", name, i, e.Inliers[i], inliers[i])
+		}
+	}
+}
+
+func allInliers(n int) []bool {
+	in := make([]bool, n)
+	for i := range in {
+		in[i] = true
+	}
+	return in
+}
+
+func TestEstimateSimilarity(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		m := randomSimilarity(r)
+		for _, n := range []int{2, 5, 50} {
+			src := randomPoints(r, n)
+			e, err := EstimateSimilarity(src, transformPoints(m, src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkEstimate(t, "similarity", e, m, allInliers(n))
+			if e.Residual > 1e-6 {
+				t.Errorf("residual %v of an exact fit", e.Residual)
+			}
+		}
+	}
+}
+
+func TestEstimateAffine(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		m := randomMatrix(r)
+		for _, n := range []int{3, 6, 50} {
+			src := randomPoints(r, n)
+			e, err := EstimateAffine(src, transformPoints(m, src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkEstimate(t, "affine", e, m, allInliers(n))
+			// the translation is up to 1000 and the points are up to 1000 away
+			if e.Residual > 1e-5 {
+				t.Errorf("residual %v of an exact fit", e.Residual)
+			}
+		}
+	}
+}
+
+func TestEstimateResidual(t *testing.T) {
+	// a square mapped to a square with one corner moved by (0.4, 0.3), which no similarity fits
+	src := []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	dst := []Point{{0, 0}, {10, 0}, {10.4, 10.3}, {0, 10}}
+	e, err := EstimateSimilarity(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for i := range src {
+		d := pointDistance(e.Matrix, src[i], dst[i])
+		sum += d * d
+	}
+	if want := math.Sqrt(sum / 4); math.Abs(e.Residual-want) > estimateTolerance {
+		t.Errorf("residual %v, want the rms distance %v", e.Residual, want)
+	}
+	if e.Residual <= 0 {
+		t.Errorf("residual %v of an inexact fit", e.Residual)
+	}
+
+	// with 3 points the affine fit is exact
+	a, err := EstimateAffine(src[1:], dst[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Residual > 1e-9 {
+		t.Errorf("affine residual %v of 3 points", a.Residual)
+	}
+}
+
+func TestEstimateRANSAC(t *testing.T) {
+	const n, outliers = 30, 9 // 30% outliers
+	for _, test := range []struct {
+		model TransformModel
+		seed  int64
+	}{
+		{Similarity, 3},
+		{Affine, 4},
+	} {
+		r := rand.New(rand.NewSource(test.seed))
+		m := randomSimilarity(r)
+		if test.model == Affine {
+			m = randomMatrix(r)
+		}
+		src := randomPoints(r, n)
+		dst := transformPoints(m, src)
+		want := allInliers(n)
+		for _, i := range r.Perm(n)[:outliers] {
+			// move the outliers at least 50 pixels away
+			angle := r.Float64() * 2 * math.Pi
+			dist := 50 + r.Float64()*200
+			dst[i].X += dist * math.Cos(angle)
+			dst[i].Y += dist * math.Sin(angle)
+			want[i] = false
+		}
+
+		opts := RANSACOptions{Model: test.model, Seed: test.seed}
+		e, err := EstimateRANSAC(src, dst, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEstimate(t, "ransac", e, m, want)
+		if e.Residual > 1e-5 {
+			t.Errorf("ransac residual %v of the inliers", e.Residual)
+		}
+
+		// the same seed gives the same estimate
+		again, err := EstimateRANSAC(src, dst, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Matrix != e.Matrix {
+			t.Errorf("seed %d: estimates %v and %v", test.seed, e.Matrix, again.Matrix)
+		}
+
+		// a least squares fit of all the points is pulled away by the outliers
+		all, err := estimate(test.model, src, dst, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matricesEqual(all.Matrix, m, 1e-3) {
+			t.Errorf("least squares fit %v ignores the outliers", all.Matrix)
+		}
+	}
+}
+
+func TestEstimateErrors(t *testing.T) {
+	collinear := []Point{{0, 0}, {1, 1}, {2, 2}, {3, 3}}
+	same := []Point{{5, 5}, {5, 5}, {5, 5}}
+	tests := []struct {
+		name     string
+		fn       func(src, dst []Point) (Estimate, error)
+		src, dst []Point
+	}{
+		{"similarity no points", EstimateSimilarity, nil, nil},
+		{"similarity 1 point", EstimateSimilarity, []Point{{1, 2}}, []Point{{3, 4}}},
+		{"similarity identical points", EstimateSimilarity, same, same},
+		{"similarity mismatched", EstimateSimilarity, collinear, collinear[:3]},
+		{"affine 2 points", EstimateAffine, collinear[:2], collinear[:2]},
+		{"affine collinear", EstimateAffine, collinear, collinear},
+		{"affine identical points", EstimateAffine, same, same},
+		{"ransac similarity 1 point", func(src, dst []Point) (Estimate, error) {
+			return EstimateRANSAC(src, dst, RANSACOptions{Model: Similarity})
+		}, []Point{{1, 2}}, []Point{{3, 4}}},
+		{"ransac affine 2 points", func(src, dst []Point) (Estimate, error) {
+			return EstimateRANSAC(src, dst, RANSACOptions{Model: Affine})
+		}, collinear[:2], collinear[:2]},
+		{"ransac affine collinear", func(src, dst []Point) (Estimate, error) {
+			return EstimateRANSAC(src, dst, RANSACOptions{Model: Affine, Iterations: 50})
+		}, collinear, collinear},
+	}
+	for _, test := range tests {
+		if _, err := test.fn(test.src, test.dst); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
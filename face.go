@@ -3,12 +3,13 @@ package tfimage
 import (
 	"fmt"
 	"image"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"math"
 	"strings"
 	"time"
 
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 	"golang.org/x/image/draw"
 )
 
@@ -21,110 +22,168 @@ const (
 // Uses a Multi-task Cascaded Convolutional Network Detector trained from:
 // https://kpzhang93.github.io/MTCNN_face_detection_alignment/
 type FaceDetector struct {
-	graph   *tf.Graph
-	session *tf.Session
+	model FaceModel
 
 	Options         FaceDetectorOptions
 	scaleFactor     float32
 	scoreThresholds []float32
+	stats           ModelStats
 }
 
 // FaceDetectorOptions -
 type FaceDetectorOptions struct {
+	ModelOptions
+
 	MinimumSize int
 	FaceWidth   int
 	FaceHeight  int
+
+	// Cascade runs the P-Net, R-Net and O-Net as separate graphs driven from Go,
+	// which exposes the candidates of each stage with DetectStages.
+	// The Backend must be a CascadeBackend.
+	Cascade bool
 }
 
 // NewFaceDetector - Create a New FaceDetector from a model file
 func NewFaceDetector(modelFile string, options FaceDetectorOptions) (*FaceDetector, error) {
-	if options.MinimumSize < 30 {
-		options.MinimumSize = 30 // default size
+	def, err := ioutil.ReadFile(modelFile)
+	if err != nil {
+		return nil, fmt.Errorf(ErrLoadFile, modelFile)
 	}
-	if options.FaceHeight == 0 || options.FaceWidth == 0 {
-		options.FaceWidth = 256
-		options.FaceHeight = 256
+	return NewFaceDetectorFromBytes(def, options)
+}
+
+// NewFaceDetectorFromBytes - Create a New FaceDetector from a serialized model
+func NewFaceDetectorFromBytes(def []byte, options FaceDetectorOptions) (*FaceDetector, error) {
+	start := time.Now()
+	backend := options.backend()
+	var model FaceModel
+	var err error
+	if options.Cascade {
+		cb, ok := backend.(CascadeBackend)
+		if !ok {
+			return nil, fmt.Errorf("backend does not support the MTCNN cascade mode")
+		}
+		model, err = cb.LoadCascadeFaceModel(def, options.ModelOptions)
+	} else {
+		model, err = backend.LoadFaceModel(def, options.ModelOptions)
 	}
-	det := &FaceDetector{scaleFactor: 0.709, scoreThresholds: []float32{0.6, 0.7, 0.8}, Options: options}
-	model, err := ioutil.ReadFile(modelFile)
 	if err != nil {
-		return nil, fmt.Errorf(ErrLoadFile, modelFile)
+		return nil, err
 	}
+	return NewFaceDetectorFromFaceModel(model, options).loaded(start, options.Warmup)
+}
 
-	det.graph = tf.NewGraph()
-	if err := det.graph.Import(model, ""); err != nil {
+// NewFaceDetectorFromReader - Create a New FaceDetector from a reader of a serialized model
+func NewFaceDetectorFromReader(r io.Reader, options FaceDetectorOptions) (*FaceDetector, error) {
+	def, err := io.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
+	return NewFaceDetectorFromBytes(def, options)
+}
 
-	det.session, err = tf.NewSession(det.graph, nil)
+// NewFaceDetectorFromFS - Create a New FaceDetector from a model file in fsys, such as an embed.FS
+func NewFaceDetectorFromFS(fsys fs.FS, name string, options FaceDetectorOptions) (*FaceDetector, error) {
+	def, err := fs.ReadFile(fsys, name)
 	if err != nil {
 		return nil, err
 	}
+	return NewFaceDetectorFromBytes(def, options)
+}
 
+// NewFaceDetectorFromRegistry - Creates a new FaceDetector from the MTCNN model name of a Registry.
+// The checksum of the model file is verified, and the Preprocess of the entry is attached if set.
+func NewFaceDetectorFromRegistry(reg *Registry, name string, options FaceDetectorOptions) (*FaceDetector, error) {
+	def, entry, err := reg.Load(name, MTCNNModel)
+	if err != nil {
+		return nil, err
+	}
+	options.Signature = entry.Signature
+	det, err := NewFaceDetectorFromBytes(def, options)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Preprocess != nil {
+		if err = det.SetPreprocess(*entry.Preprocess); err != nil {
+			det.Close()
+			return nil, err
+		}
+	}
 	return det, nil
 }
 
+// NewFaceDetectorFromFaceModel - Create a New FaceDetector from a FaceModel loaded by a Backend.
+// The FaceDetector takes ownership of the FaceModel; options.ModelOptions is not used.
+func NewFaceDetectorFromFaceModel(model FaceModel, options FaceDetectorOptions) *FaceDetector {
+	if options.MinimumSize < 30 {
+		options.MinimumSize = 30 // default size
+	}
+	if options.FaceHeight == 0 || options.FaceWidth == 0 {
+		options.FaceWidth = 256
+		options.FaceHeight = 256
+	}
+	return &FaceDetector{model: model, scaleFactor: 0.709, scoreThresholds: []float32{0.6, 0.7, 0.8}, Options: options}
+}
+
 // Close - Close a FaceDetector Session
 func (det *FaceDetector) Close() {
-	if det.session != nil {
-		det.session.Close()
-		det.session = nil
+	det.model.Close()
+}
+
+// loaded records the load time of the FaceDetector since start and runs the warm-up
+func (det *FaceDetector) loaded(start time.Time, warmup WarmupOptions) (*FaceDetector, error) {
+	det.stats.LoadTime = time.Since(start)
+	err := warmup.warmup(&det.stats, func(img *ImageData) error {
+		_, err := det.model.DetectFaces(img, det.params())
+		return err
+	})
+	if err != nil {
+		det.Close()
+		return nil, err
 	}
+	return det, nil
+}
+
+// Stats returns the load and warm-up timings of the FaceDetector
+func (det *FaceDetector) Stats() ModelStats {
+	return det.stats
+}
+
+// params returns the MTCNNParams of the FaceDetector
+func (det *FaceDetector) params() MTCNNParams {
+	p := MTCNNParams{MinSize: float32(det.Options.MinimumSize), Factor: det.scaleFactor}
+	copy(p.Thresholds[:], det.scoreThresholds)
+	return p
 }
 
 //
 //
 //
 
-// DetectFaces runs the tensorflow detection session and outputs a FacesResults
-func (det *FaceDetector) DetectFaces(tensor *tf.Tensor) (*FaceResults, error) {
+// DetectImage runs the face detection on an image and outputs a FaceResults
+func (det *FaceDetector) DetectImage(img image.Image) (*FaceResults, error) {
 	start := time.Now()
-	minSize, err := tf.NewTensor(float32(det.Options.MinimumSize))
-	if err != nil {
-		return nil, fmt.Errorf("error minimum size: %v", err)
-	}
-	threshold, err := tf.NewTensor(det.scoreThresholds)
-	if err != nil {
-		return nil, fmt.Errorf("error score threshold: %v", err)
-	}
-	factor, err := tf.NewTensor(det.scaleFactor)
+	faces, err := det.model.DetectFaces(NewImageData(img), det.params())
 	if err != nil {
-		return nil, fmt.Errorf("error scale factor: %v", err)
+		return nil, err
 	}
+	return &FaceResults{results: faces, d: time.Since(start)}, nil
+}
 
-	graph := det.graph
-	output, err := det.session.Run(
-		map[tf.Output]*tf.Tensor{
-			graph.Operation("sub").Output(0):        tensor,
-			graph.Operation("min_size").Output(0):   minSize,
-			graph.Operation("thresholds").Output(0): threshold,
-			graph.Operation("factor").Output(0):     factor,
-		},
-		[]tf.Output{
-			graph.Operation("prob").Output(0),
-			graph.Operation("landmarks").Output(0),
-			graph.Operation("box").Output(0),
-		},
-		nil,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error tensorflow Face Detection: %v", err)
+// DetectStages runs the face detection on an image and also returns the candidates
+// of each stage of the MTCNN cascade. It requires a FaceDetector in cascade mode.
+func (det *FaceDetector) DetectStages(img image.Image) (*FaceResults, []MTCNNStage, error) {
+	m, ok := det.model.(CascadeFaceModel)
+	if !ok {
+		return nil, nil, fmt.Errorf("face detector is not in cascade mode")
 	}
-
-	res := &FaceResults{}
-
-	if len(output) > 0 {
-		prob := output[0].Value().([]float32)
-		landmarks := output[1].Value().([][]float32)
-		bbox := output[2].Value().([][]float32)
-
-		res.results = make([]Face, len(prob))
-		for i := 0; i < len(prob); i++ {
-			res.results[i] = newFace(prob[i], bbox[i], landmarks[i])
-		}
+	start := time.Now()
+	faces, stages, err := m.DetectStages(NewImageData(img), det.params())
+	if err != nil {
+		return nil, nil, err
 	}
-	res.d = time.Since(start)
-	return res, nil
+	return &FaceResults{results: faces, d: time.Since(start)}, stages, nil
 }
 
 type FaceResults struct {
@@ -132,6 +191,21 @@ type FaceResults struct {
 	d       time.Duration
 }
 
+// NewFaceResults creates a FaceResults of faces detected in d
+func NewFaceResults(faces []Face, d time.Duration) *FaceResults {
+	return &FaceResults{results: faces, d: d}
+}
+
+// Faces returns the detected faces
+func (fr FaceResults) Faces() []Face {
+	return fr.results
+}
+
+// Duration returns the duration of the detection
+func (fr FaceResults) Duration() time.Duration {
+	return fr.d
+}
+
 func (fr FaceResults) String() string {
 	sb := strings.Builder{}
 	sb.WriteString(fmt.Sprintf("%d Faces detected.\t in %s \n", fr.Len(), fr.d))
@@ -145,12 +219,54 @@ func (fr FaceResults) Len() int {
 	return len(fr.results)
 }
 
+// ToJPEG calls fn with the aligned crop of each face in src, with the default template
+// of width by height. EncodeCrops encodes the crops with any Encoder.
 func (fr FaceResults) ToJPEG(src image.Image, kernel draw.Interpolator, width uint16, height uint16, fn func(image.Image) error) (err error) {
-	faceImage := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-	for _, f := range fr.results {
-		m := f.AffineMatrix(width, height).ToAffineMatrix()
-		kernel.Transform(faceImage, m, src, src.Bounds(), draw.Src, nil)
-		if err = fn(faceImage); err != nil {
+	return fr.AlignImages(src, kernel, DefaultAlignOptions(int(width), int(height)), fn)
+}
+
+// AlignImages calls fn with the aligned crop of each face in src, with the template of opts.
+// Each call gets its own image.
+func (fr FaceResults) AlignImages(src image.Image, kernel draw.Interpolator, opts AlignOptions, fn func(image.Image) error) (err error) {
+	return fr.EachCrop(src, kernel, opts, false, func(c FaceCrop) error {
+		return fn(c.Image)
+	})
+}
+
+// FaceCrop - The aligned crop of a face of FaceResults
+type FaceCrop struct {
+	Index  int    // index of the face in the FaceResults
+	Face   Face   // the face, in source image coordinates
+	Matrix Matrix // maps the source image to the crop
+	Image  *image.RGBA
+}
+
+// Crops returns an independent aligned crop of each face in src, with the template of opts
+func (fr FaceResults) Crops(src image.Image, kernel draw.Interpolator, opts AlignOptions) []FaceCrop {
+	crops := make([]FaceCrop, 0, len(fr.results))
+	_ = fr.EachCrop(src, kernel, opts, false, func(c FaceCrop) error {
+		crops = append(crops, c)
+		return nil
+	})
+	return crops
+}
+
+// EachCrop calls fn with the aligned crop of each face in src, with the template of opts,
+// and stops at the first error. Each crop has its own image, unless reuse is set: then a
+// single image is cleared and reused for every face, and fn must not keep it after returning.
+func (fr FaceResults) EachCrop(src image.Image, kernel draw.Interpolator, opts AlignOptions, reuse bool, fn func(FaceCrop) error) error {
+	var buf *image.RGBA
+	for i, f := range fr.results {
+		if buf == nil || !reuse {
+			buf = image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+		} else {
+			for j := range buf.Pix {
+				buf.Pix[j] = 0
+			}
+		}
+		m := f.AlignMatrix(opts)
+		kernel.Transform(buf, m.ToAffineMatrix(), src, src.Bounds(), draw.Src, nil)
+		if err := fn(FaceCrop{Index: i, Face: f, Matrix: m, Image: buf}); err != nil {
 			return err
 		}
 	}
@@ -161,6 +277,13 @@ func (fr FaceResults) ToJPEG(src image.Image, kernel draw.Interpolator, width ui
 //
 //
 
+// NewFace creates a Face with a probability, a bounding box [y1, x1, y2, x2] and landmarks
+// in the layout of the MTCNN output: the y coordinates of the left eye, right eye, nose,
+// left mouth and right mouth, followed by their x coordinates.
+func NewFace(probability float32, box [4]float32, landmarks [10]float32) Face {
+	return Face{p: probability, box: box, landmarks: landmarks}
+}
+
 // newFace creates a new face with probability, bounding box, and landmarks
 func newFace(probablity float32, bbox []float32, landmarks []float32) Face {
 	fr := Face{p: probablity}
@@ -176,11 +299,67 @@ type Face struct {
 	p         float32
 }
 
+// transform maps the box and landmarks of the face through the matrix.
+// The box becomes the bounding box of its mapped corners.
+func (f Face) transform(m Matrix) Face {
+	box := [4]float32{float32(math.Inf(1)), float32(math.Inf(1)), float32(math.Inf(-1)), float32(math.Inf(-1))}
+	for _, c := range [4][2]float32{{f.box[1], f.box[0]}, {f.box[3], f.box[0]}, {f.box[1], f.box[2]}, {f.box[3], f.box[2]}} {
+		x, y := m.TransformPoint(float64(c[0]), float64(c[1]))
+		box[0], box[1] = minFloat(box[0], float32(y)), minFloat(box[1], float32(x))
+		box[2], box[3] = maxFloat(box[2], float32(y)), maxFloat(box[3], float32(x))
+	}
+	f.box = box
+	for i := 0; i < 5; i++ {
+		x, y := m.TransformPoint(float64(f.landmarks[i+5]), float64(f.landmarks[i]))
+		f.landmarks[i+5], f.landmarks[i] = float32(x), float32(y)
+	}
+	return f
+}
+
+// clip clips the box and landmarks of the face to a width by height image
+func (f Face) clip(width, height float32) Face {
+	clamp := func(v, max float32) float32 {
+		return minFloat(maxFloat(v, 0), max)
+	}
+	f.box = [4]float32{clamp(f.box[0], height), clamp(f.box[1], width), clamp(f.box[2], height), clamp(f.box[3], width)}
+	for i := 0; i < 5; i++ {
+		f.landmarks[i] = clamp(f.landmarks[i], height)
+		f.landmarks[i+5] = clamp(f.landmarks[i+5], width)
+	}
+	return f
+}
+
+// ToCrop returns the Face in the coordinates of its aligned crop, as made by AlignImage
+// with opts. The box and landmarks are mapped through AlignMatrix and clipped to the crop.
+// The box becomes the bounding box of the mapped box, so it is larger than the face
+// when the face is tilted.
+func (f Face) ToCrop(opts AlignOptions) Face {
+	return f.transform(f.AlignMatrix(opts)).clip(float32(opts.Width), float32(opts.Height))
+}
+
+// FromCrop maps crop, a Face in the coordinates of the aligned crop of f made with opts,
+// back to the coordinates of the source image, so that landmarks refined on the crop
+// can be placed in the source image. Landmarks inside the crop round-trip through
+// ToCrop and FromCrop. Boxes do not: ToCrop clips the box to the crop, and each
+// mapping of a tilted box takes its bounding box, which grows on every pass.
+func (f Face) FromCrop(crop Face, opts AlignOptions) (Face, error) {
+	inv, err := f.AlignMatrix(opts).Invert()
+	if err != nil {
+		return Face{}, err
+	}
+	return crop.transform(inv), nil
+}
+
 func (f Face) String() string {
 	w, h := f.Size()
 	return fmt.Sprintf(" Probability: %.2f%% \t Size: %dx%d \t Angle: %.4f \n", f.p*100, w, h, f.Angle())
 }
 
+// Probability returns the probability of the face
+func (f Face) Probability() float32 {
+	return f.p
+}
+
 // Size returns the width and height of the face in the source image
 func (f Face) Size() (width int, height int) {
 	width = int(f.box[3]) - int(f.box[1])
@@ -238,11 +417,55 @@ func (f Face) Angle() float64 {
 	return math.Atan2(y0-y1, x0-x1)
 }
 
-//AffineMatrix builds a Face Warp Affine Matrix
+// AlignOptions - The template of an aligned face crop. Eye positions are fractions
+// of the width and height of the crop, inside the margin.
+// The zero value of the eye positions uses the default template.
+type AlignOptions struct {
+	// Width and Height are the size of the crop
+	Width, Height int
+	// LeftEyeX and RightEyeX are the x positions of the eyes. A zero RightEyeX
+	// mirrors LeftEyeX, which centers the face horizontally.
+	LeftEyeX, RightEyeX float64
+	// EyeY is the y position of the eyes
+	EyeY float64
+	// Margin is the fraction of the width and height added as a border on each
+	// side of the template, which shrinks the face into the crop.
+	Margin float64
+}
+
+// Default alignment template
+const (
+	DefaultAlignLeftEyeX = 0.33
+	DefaultAlignEyeY     = 0.30
+)
+
+// DefaultAlignOptions returns the default AlignOptions of a width by height crop
+func DefaultAlignOptions(width, height int) AlignOptions {
+	return AlignOptions{Width: width, Height: height, LeftEyeX: DefaultAlignLeftEyeX, EyeY: DefaultAlignEyeY}
+}
+
+// eyes returns the positions of the eyes in the crop
+func (o AlignOptions) eyes() (leftX, rightX, y float64) {
+	leftX, rightX, y = o.LeftEyeX, o.RightEyeX, o.EyeY
+	if leftX == 0 && rightX == 0 && y == 0 {
+		leftX, y = DefaultAlignLeftEyeX, DefaultAlignEyeY
+	}
+	if rightX == 0 {
+		rightX = 1 - leftX
+	}
+	w, h := float64(o.Width), float64(o.Height)
+	mx, my := w*o.Margin, h*o.Margin
+	return mx + leftX*(w-2*mx), mx + rightX*(w-2*mx), my + y*(h-2*my)
+}
+
+// AffineMatrix builds a Face Warp Affine Matrix
 func (f *Face) AffineMatrix(width, height uint16) Matrix {
-	// Output Size
-	//f.dstFaceWidth, f.dstFaceHeight = faceWidth, faceHeight
-	desiredLeftEyeX, desiredLeftEyeY := 0.33, 0.30
+	return f.AlignMatrix(DefaultAlignOptions(int(width), int(height)))
+}
+
+// AlignMatrix builds the Matrix that maps the source image to the aligned crop of opts
+func (f *Face) AlignMatrix(opts AlignOptions) Matrix {
+	desiredLeftEyeX, desiredRightEyeX, desiredEyeY := opts.eyes()
 
 	// Eye Points
 	x1, y1 := f.LeftEye()
@@ -257,45 +480,34 @@ func (f *Face) AffineMatrix(width, height uint16) Matrix {
 	dist := math.Sqrt((dX * dX) + (dY * dY))
 
 	// Determine the scale of the resulting image by taking the ratio
-	// of the distance between the eyes in the image and ratio of the
+	// of the distance between the eyes in the image and the
 	// distance between the eyes in the output image
-	desiredRightEyeX := 1.0 - desiredLeftEyeX
-	desiredDist := (desiredRightEyeX - desiredLeftEyeX)
-	desiredDist *= float64(width)
-
-	// Set the translation Matrix with the desired positions of the eyes
-	tX := float64(width) * 0.5
-	tY := float64(height) * desiredLeftEyeY
+	scale := (desiredRightEyeX - desiredLeftEyeX) / dist
 
-	// Caclulate the scale of the face
-	scale := desiredDist / dist
+	// Set the translation with the desired position of the center of the eyes
+	tX := (desiredLeftEyeX + desiredRightEyeX) / 2
+	tY := desiredEyeY
 
 	// Calculate the mean distance between the eyes
 	eyesX, eyesY := f.EyesCenter()
 
-	// Center the matrix around the eyes
-	//f.matrix = f.matrix.Translate(eyesX, eyesY)
-
-	// Adjust the scale of the matrix
-	//f.matrix = f.matrix.AdjustScale(scale)
-
-	// Rotate Matrix in the opposite direction of the angle of the eyes
-	//f.matrix = f.matrix.Rotate(angle * -1)
-
+	// Rotate and scale around the eyes
 	matrix := RotationMatrix2D(eyesX, eyesY, angle, scale)
 
 	// Adjust position of the image
 	matrix.AdjustPosition((tX - eyesX), (tY - eyesY))
-
-	// unCenter the matrix from around the eyes
-	//f.matrix = f.matrix.Translate(-eyesX, -eyesY)
 	return matrix
 }
 
 // ToImage transforms an image by the matrix and returns the face image
 func (f Face) ToImage(srcImage image.Image, kernel draw.Interpolator, width uint16, height uint16) image.Image {
-	faceImg := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-	s2d := f.AffineMatrix(width, height).ToAffineMatrix()
+	return f.AlignImage(srcImage, kernel, DefaultAlignOptions(int(width), int(height)))
+}
+
+// AlignImage returns the aligned crop of the face in srcImage with the template of opts
+func (f Face) AlignImage(srcImage image.Image, kernel draw.Interpolator, opts AlignOptions) image.Image {
+	faceImg := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	s2d := f.AlignMatrix(opts).ToAffineMatrix()
 	kernel.Transform(faceImg, s2d, srcImage, srcImage.Bounds(), draw.Src, nil)
 	return faceImg
 }
@@ -0,0 +1,230 @@
+package tfimage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func TestFaceCropRoundTrip(t *testing.T) {
+	opts := DefaultAlignOptions(112, 112)
+	for _, f := range []Face{
+		// upright 100x120 face
+		NewFace(0.99, [4]float32{100, 200, 220, 300}, [10]float32{150, 150, 190, 190, 170, 230, 270, 235, 265, 250}),
+		// tilted face
+		NewFace(0.99, [4]float32{100, 200, 220, 300}, [10]float32{140, 160, 195, 185, 170, 230, 268, 240, 262, 252}),
+	} {
+		crop := f.ToCrop(opts)
+		back, err := f.FromCrop(crop, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range f.landmarks {
+			if math.Abs(float64(back.landmarks[i]-v)) > 1e-3 {
+				t.Errorf("landmark value %d = %v after the round trip, want %v", i, back.landmarks[i], v)
+			}
+		}
+		if back.p != f.p {
+			t.Errorf("probability %v, want %v", back.p, f.p)
+		}
+	}
+}
+
+func TestFaceCropClipsBox(t *testing.T) {
+	// The default template crops the 120 pixel high box, so the box does not round-trip
+	f := NewFace(0.99, [4]float32{100, 200, 220, 300}, [10]float32{150, 150, 190, 190, 170, 230, 270, 235, 265, 250})
+	opts := DefaultAlignOptions(112, 112)
+	back, err := f.FromCrop(f.ToCrop(opts), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := back.box[2] - back.box[0]; h >= f.box[2]-f.box[0] {
+		t.Errorf("box height %v after the round trip, want less than %v", h, f.box[2]-f.box[0])
+	}
+}
+
+// testTiltedFace is a face with its eyes at (230,140) and (270,195)
+var testTiltedFace = NewFace(0.99, [4]float32{100, 200, 220, 300}, [10]float32{140, 195, 170, 175, 160, 230, 270, 240, 262, 252})
+
+func TestAlignMatrixDefault(t *testing.T) {
+	f := testTiltedFace
+	for _, size := range [][2]uint16{{112, 112}, {160, 200}} {
+		width, height := float64(size[0]), float64(size[1])
+		// the template of AffineMatrix before AlignOptions: the eyes 0.34 of the
+		// width apart, centered horizontally at 0.3 of the height
+		x1, y1 := f.LeftEye()
+		x2, y2 := f.RightEye()
+		eyesX, eyesY := f.EyesCenter()
+		want := RotationMatrix2D(eyesX, eyesY, math.Atan2(y2-y1, x2-x1), 0.34*width/math.Hypot(x2-x1, y2-y1))
+		want.AdjustPosition(width*0.5-eyesX, height*0.3-eyesY)
+
+		if m := f.AffineMatrix(size[0], size[1]); !matricesEqual(m, want, 1e-9) {
+			t.Errorf("%v: AffineMatrix %v, want %v", size, m, want)
+		}
+		// the zero template is the default template
+		if m := f.AlignMatrix(AlignOptions{Width: int(size[0]), Height: int(size[1])}); !matricesEqual(m, want, 1e-9) {
+			t.Errorf("%v: zero template %v, want %v", size, m, want)
+		}
+	}
+}
+
+func TestAlignMatrixTemplate(t *testing.T) {
+	f := testTiltedFace
+	for _, opts := range []AlignOptions{
+		DefaultAlignOptions(112, 112),
+		{Width: 112, Height: 112, LeftEyeX: 0.35, EyeY: 0.4},
+		{Width: 96, Height: 128, LeftEyeX: 0.3, RightEyeX: 0.6, EyeY: 0.35},
+		{Width: 112, Height: 112, LeftEyeX: 0.33, EyeY: 0.3, Margin: 0.1},
+	} {
+		rightX := opts.RightEyeX
+		if rightX == 0 {
+			rightX = 1 - opts.LeftEyeX
+		}
+		w, h := float64(opts.Width), float64(opts.Height)
+		mx, my := w*opts.Margin, h*opts.Margin
+		wantLeft := Point{mx + opts.LeftEyeX*(w-2*mx), my + opts.EyeY*(h-2*my)}
+		wantRight := Point{mx + rightX*(w-2*mx), wantLeft.Y}
+
+		m := f.AlignMatrix(opts)
+		for _, eye := range []struct {
+			name string
+			want Point
+			fn   func() (float64, float64)
+		}{
+			{"left eye", wantLeft, f.LeftEye},
+			{"right eye", wantRight, f.RightEye},
+		} {
+			x, y := m.TransformPoint(eye.fn())
+			if math.Hypot(x-eye.want.X, y-eye.want.Y) > 1e-9 {
+				t.Errorf("%+v: %s at (%v,%v), want (%v,%v)", opts, eye.name, x, y, eye.want.X, eye.want.Y)
+			}
+		}
+	}
+}
+
+func TestAlignImage(t *testing.T) {
+	f := testTiltedFace
+	src := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 7)
+	}
+	want := f.AlignImage(src, draw.BiLinear, DefaultAlignOptions(112, 96))
+	if got := f.ToImage(src, draw.BiLinear, 112, 96); !reflect.DeepEqual(got, want) {
+		t.Error("ToImage differs from AlignImage with the default template")
+	}
+
+	opts := AlignOptions{Width: 80, Height: 100, LeftEyeX: 0.3, EyeY: 0.4, Margin: 0.05}
+	img := f.AlignImage(src, draw.NearestNeighbor, opts)
+	if b := img.Bounds(); b != image.Rect(0, 0, 80, 100) {
+		t.Fatalf("bounds %v, want 80x100", b)
+	}
+	// each crop pixel samples the source pixel mapped to it by AlignMatrix
+	inv, err := f.AlignMatrix(opts).Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []image.Point{{10, 10}, {40, 50}, {70, 90}} {
+		sx, sy := inv.TransformPoint(float64(p.X)+0.5, float64(p.Y)+0.5)
+		if got, want := img.At(p.X, p.Y), src.At(int(math.Floor(sx)), int(math.Floor(sy))); got != want {
+			t.Errorf("pixel %v %v, want %v of the source at (%.1f,%.1f)", p, got, want, sx, sy)
+		}
+	}
+}
+
+// testCropFaces are a face inside of a 400x300 image and a face at its left edge,
+// whose crop is partly outside of the image
+var testCropFaces = []Face{
+	NewFace(0.99, [4]float32{100, 200, 220, 300}, [10]float32{150, 150, 190, 190, 170, 230, 270, 250, 235, 265}),
+	NewFace(0.95, [4]float32{100, -10, 220, 90}, [10]float32{150, 150, 190, 190, 170, 20, 60, 40, 25, 55}),
+}
+
+func testCropSource() *image.RGBA {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for i := range src.Pix {
+		if i%4 == 3 {
+			src.Pix[i] = 255
+		} else {
+			src.Pix[i] = uint8(i * 11)
+		}
+	}
+	return src
+}
+
+func TestEachCrop(t *testing.T) {
+	src := testCropSource()
+	fr := NewFaceResults(testCropFaces, 0)
+	opts := DefaultAlignOptions(112, 112)
+	for _, reuse := range []bool{false, true} {
+		var images []*image.RGBA
+		err := fr.EachCrop(src, draw.BiLinear, opts, reuse, func(c FaceCrop) error {
+			f := testCropFaces[len(images)]
+			if c.Index != len(images) || c.Face != f {
+				t.Errorf("reuse %v: crop %d of face %v, want %d of %v", reuse, c.Index, c.Face, len(images), f)
+			}
+			if m := f.AlignMatrix(opts); c.Matrix != m {
+				t.Errorf("reuse %v: crop %d matrix %v, want %v", reuse, c.Index, c.Matrix, m)
+			}
+			// the crop is complete during the call, also when its image is reused
+			if want := f.AlignImage(src, draw.BiLinear, opts); !reflect.DeepEqual(c.Image, want) {
+				t.Errorf("reuse %v: crop %d differs from AlignImage", reuse, c.Index)
+			}
+			images = append(images, c.Image)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(images) != len(testCropFaces) {
+			t.Fatalf("reuse %v: %d crops, want %d", reuse, len(images), len(testCropFaces))
+		}
+		if shared := images[0] == images[1]; shared != reuse {
+			t.Errorf("reuse %v: crops share their image: %v", reuse, shared)
+		}
+	}
+
+	// the reused image is cleared, so the part of the second crop outside of src is transparent
+	var corner color.RGBA
+	_ = fr.EachCrop(src, draw.BiLinear, opts, true, func(c FaceCrop) error {
+		corner = c.Image.RGBAAt(0, 0)
+		return nil
+	})
+	if corner != (color.RGBA{}) {
+		t.Errorf("corner of the reused crop %v, want transparent", corner)
+	}
+}
+
+func TestEachCropError(t *testing.T) {
+	errStop := errors.New("stop")
+	calls := 0
+	err := NewFaceResults(testCropFaces, 0).EachCrop(testCropSource(), draw.BiLinear, DefaultAlignOptions(64, 64), true,
+		func(c FaceCrop) error {
+			calls++
+			return errStop
+		})
+	if err != errStop || calls != 1 {
+		t.Errorf("error %v after %d calls, want %v after 1", err, calls, errStop)
+	}
+}
+
+func TestCrops(t *testing.T) {
+	src := testCropSource()
+	opts := AlignOptions{Width: 96, Height: 112, LeftEyeX: 0.3, EyeY: 0.35}
+	crops := NewFaceResults(testCropFaces, 0).Crops(src, draw.BiLinear, opts)
+	if len(crops) != len(testCropFaces) {
+		t.Fatalf("%d crops, want %d", len(crops), len(testCropFaces))
+	}
+	if crops[0].Image == crops[1].Image {
+		t.Fatal("crops share their image")
+	}
+	// the crops are independent after the iteration
+	for i, c := range crops {
+		if want := testCropFaces[i].AlignImage(src, draw.BiLinear, opts); c.Index != i || !reflect.DeepEqual(c.Image, want) {
+			t.Errorf("crop %d (index %d) differs from AlignImage", i, c.Index)
+		}
+	}
+}
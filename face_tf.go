@@ -0,0 +1,142 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"fmt"
+	"time"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// MTCNNSignature returns the Signature of the bundled MTCNN models
+func MTCNNSignature() Signature {
+	return Signature{
+		Inputs: map[string]string{
+			ImageInput:   "sub",
+			"min_size":   "min_size",
+			"thresholds": "thresholds",
+			"factor":     "factor",
+		},
+		Outputs: map[string]string{
+			"prob":      "prob",
+			"landmarks": "landmarks",
+			"box":       "box",
+		},
+		Specs: map[string]TensorSpec{
+			ImageInput:   {DType: tf.Float, Rank: 4},
+			"min_size":   {DType: tf.Float, Rank: 0},
+			"thresholds": {DType: tf.Float, Rank: 1},
+			"factor":     {DType: tf.Float, Rank: 0},
+			"prob":       {DType: tf.Float, Rank: 1},
+			"landmarks":  {DType: tf.Float, Rank: 2},
+			"box":        {DType: tf.Float, Rank: 2},
+		},
+	}
+}
+
+// NewFaceDetectorFromSavedModel - Create a New FaceDetector from a SavedModel directory.
+// The inputs and outputs of the MTCNNSignature are resolved from options.SavedModel.
+func NewFaceDetectorFromSavedModel(exportDir string, options FaceDetectorOptions) (*FaceDetector, error) {
+	start := time.Now()
+	model, err := NewModelFromSavedModel(exportDir, MTCNNSignature(), options.ModelOptions)
+	if err != nil {
+		return nil, err
+	}
+	return NewFaceDetectorFromModel(model, options).loaded(start, options.Warmup)
+}
+
+// NewFaceDetectorFromModel - Create a New FaceDetector from a Model with an MTCNNSignature.
+// The FaceDetector takes ownership of the Model; options.ModelOptions is not used.
+func NewFaceDetectorFromModel(model *Model, options FaceDetectorOptions) *FaceDetector {
+	return NewFaceDetectorFromFaceModel(&tfFaceModel{model: model}, options)
+}
+
+// SetPreprocess attaches a Preprocess that is applied to image tensors before detection.
+// Detected faces are mapped back to the coordinates of the original image.
+// It is supported by the TensorflowBackend.
+func (det *FaceDetector) SetPreprocess(p Preprocess) error {
+	m, ok := det.model.(*tfFaceModel)
+	if !ok {
+		return fmt.Errorf("preprocess is not supported by the backend of the face detector")
+	}
+	return m.model.SetPreprocess(p)
+}
+
+// DetectFaces runs the tensorflow detection session and outputs a FacesResults
+func (det *FaceDetector) DetectFaces(tensor *tf.Tensor) (*FaceResults, error) {
+	start := time.Now()
+	var faces []Face
+	var err error
+	if m, ok := det.model.(*tfFaceModel); ok {
+		faces, err = m.detect(tensor, det.params())
+	} else {
+		var img *ImageData
+		if img, err = imageDataFromTensor(tensor); err == nil {
+			faces, err = det.model.DetectFaces(img, det.params())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &FaceResults{results: faces, d: time.Since(start)}, nil
+}
+
+// tfFaceModel is an MTCNN Model of the TensorflowBackend
+type tfFaceModel struct {
+	model *Model
+}
+
+func (m *tfFaceModel) DetectFaces(img *ImageData, params MTCNNParams) ([]Face, error) {
+	tensor, err := img.tensor()
+	if err != nil {
+		return nil, err
+	}
+	return m.detect(tensor, params)
+}
+
+func (m *tfFaceModel) detect(tensor *tf.Tensor, params MTCNNParams) ([]Face, error) {
+	minSize, err := tf.NewTensor(params.MinSize)
+	if err != nil {
+		return nil, fmt.Errorf("error minimum size: %v", err)
+	}
+	threshold, err := tf.NewTensor(params.Thresholds[:])
+	if err != nil {
+		return nil, fmt.Errorf("error score threshold: %v", err)
+	}
+	factor, err := tf.NewTensor(params.Factor)
+	if err != nil {
+		return nil, fmt.Errorf("error scale factor: %v", err)
+	}
+
+	output, inverse, err := m.model.run(
+		map[string]*tf.Tensor{
+			ImageInput:   tensor,
+			"min_size":   minSize,
+			"thresholds": threshold,
+			"factor":     factor,
+		},
+		[]string{"prob", "landmarks", "box"},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error tensorflow Face Detection: %v", err)
+	}
+
+	var faces []Face
+	if len(output) > 0 {
+		prob := output["prob"].Value().([]float32)
+		landmarks := output["landmarks"].Value().([][]float32)
+		bbox := output["box"].Value().([][]float32)
+
+		faces = make([]Face, len(prob))
+		for i := 0; i < len(prob); i++ {
+			faces[i] = newFace(prob[i], bbox[i], landmarks[i]).transform(inverse)
+		}
+	}
+	return faces, nil
+}
+
+func (m *tfFaceModel) Close() {
+	m.model.Close()
+}
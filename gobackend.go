@@ -0,0 +1,81 @@
+package tfimage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GoBackend runs the bundled MTCNN and NIMA models in pure Go, without libtensorflow.
+// It reads the weights of the frozen graphs and executes their operations with
+// Go kernels, so models are loaded from GraphDefs only. Session options are ignored.
+var GoBackend Backend = goBackend{}
+
+type goBackend struct{}
+
+func (goBackend) LoadFaceModel(def []byte, options ModelOptions) (FaceModel, error) {
+	g, err := parseGraphDef(def)
+	if err != nil {
+		return nil, err
+	}
+	nets, err := newGoMTCNN(g)
+	if err != nil {
+		return nil, err
+	}
+	return &cascadeModel{nets: nets}, nil
+}
+
+// LoadCascadeFaceModel loads an MTCNN model, the GoBackend always runs the cascade from Go
+func (b goBackend) LoadCascadeFaceModel(def []byte, options ModelOptions) (CascadeFaceModel, error) {
+	m, err := b.LoadFaceModel(def, options)
+	if err != nil {
+		return nil, err
+	}
+	return m.(CascadeFaceModel), nil
+}
+
+func (goBackend) LoadAestheticsModel(def []byte, options ModelOptions) (AestheticsModel, error) {
+	g, err := parseGraphDef(def)
+	if err != nil {
+		return nil, err
+	}
+	input, output := nimaInput, nimaOutput
+	if name, ok := options.Signature.Inputs[ImageInput]; ok {
+		input = name
+	}
+	if name, ok := options.Signature.Outputs["scores"]; ok {
+		output = name
+	}
+	m := &goAestheticsModel{graph: &goGraph{def: g, input: input, output: output}}
+	for _, name := range []string{input, output} {
+		if _, err := g.node(name); err != nil {
+			return nil, fmt.Errorf("aesthetics model: %v", err)
+		}
+	}
+	return m, nil
+}
+
+var errModelClosed = errors.New("model is closed")
+
+// goAestheticsModel is a NIMA model of the GoBackend
+type goAestheticsModel struct {
+	graph *goGraph
+}
+
+func (m *goAestheticsModel) Scores(img *ImageData) ([]float32, error) {
+	if m.graph == nil {
+		return nil, errModelClosed
+	}
+	if err := img.check(); err != nil {
+		return nil, err
+	}
+	x := &goTensor{shape: []int{1, img.Height, img.Width, 3}, data: img.Pix}
+	out, err := m.graph.run(x)
+	if err != nil {
+		return nil, fmt.Errorf("aesthetics model: %v", err)
+	}
+	return out.data, nil
+}
+
+func (m *goAestheticsModel) Close() {
+	m.graph = nil
+}
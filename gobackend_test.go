@@ -0,0 +1,43 @@
+//go:build !cgo || notensorflow
+// +build !cgo notensorflow
+
+package tfimage
+
+import (
+	"math"
+	"testing"
+)
+
+// Golden MTCNN result of testdata/grace_hopper.jpg, as detected by tensorflow
+var graceHopperFace = struct {
+	p   float32
+	box [4]float32
+}{0.9998, [4]float32{111, 177, 339, 353}}
+
+func TestGoBackendMTCNNGolden(t *testing.T) {
+	// GoBackend is the default backend without tensorflow
+	det, err := NewFaceDetectorFromBytes(readModel(t, "mtcnn_1.14.pb"), FaceDetectorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer det.Close()
+
+	res, err := det.DetectImage(testImages(t)["grace_hopper"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	faces := res.Faces()
+	if len(faces) != 1 {
+		t.Fatalf("%d faces, want 1", len(faces))
+	}
+	f := faces[0]
+	if math.Abs(float64(f.p-graceHopperFace.p)) > 1e-3 {
+		t.Errorf("probability %v, want %v", f.p, graceHopperFace.p)
+	}
+	for i, v := range graceHopperFace.box {
+		if math.Abs(float64(f.box[i]-v)) > 1 {
+			t.Errorf("box %v, want %v", f.box, graceHopperFace.box)
+			break
+		}
+	}
+}
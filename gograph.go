@@ -0,0 +1,656 @@
+package tfimage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// goGraph evaluates a frozen feed forward graph in Go, such as the Keras
+// MobileNet graph of the NIMA models. It supports the operations of
+// convolutional classifiers; the conditionals of the Keras learning phase are
+// evaluated with the learning phase off.
+type goGraph struct {
+	def           *graphDef
+	input, output string
+}
+
+// learningPhase is the placeholder that Keras uses to switch between training and inference
+const learningPhase = "keras_learning_phase"
+
+// run feeds input to the graph and returns the output tensor
+func (g *goGraph) run(input *goTensor) (*goTensor, error) {
+	values := map[string][]*goTensor{}
+	var eval func(name string) (*goTensor, error)
+	eval = func(name string) (*goTensor, error) {
+		nodeName, index := name, 0
+		if i := strings.LastIndexByte(name, ':'); i >= 0 {
+			nodeName = name[:i]
+			var err error
+			if index, err = strconv.Atoi(name[i+1:]); err != nil {
+				return nil, fmt.Errorf("invalid tensor name %q", name)
+			}
+		}
+		outs, ok := values[nodeName]
+		if !ok {
+			n, err := g.def.node(nodeName)
+			if err != nil {
+				return nil, err
+			}
+			if outs, err = g.evalNode(n, input, eval); err != nil {
+				return nil, fmt.Errorf("%s (%s): %v", n.name, n.op, err)
+			}
+			values[nodeName] = outs
+		}
+		if index >= len(outs) {
+			return nil, fmt.Errorf("operation %s has no output %d", nodeName, index)
+		}
+		return outs[index], nil
+	}
+
+	out, err := eval(g.output)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, fmt.Errorf("output %s was not computed", g.output)
+	}
+	return out, nil
+}
+
+// evalNode computes the outputs of n. A nil output is an untaken branch of a Switch.
+func (g *goGraph) evalNode(n *graphNode, input *goTensor, eval func(string) (*goTensor, error)) ([]*goTensor, error) {
+	var args []*goTensor
+	for _, in := range n.inputs {
+		if strings.HasPrefix(in, "^") {
+			continue // control dependency
+		}
+		if n.op == "Merge" {
+			t, err := eval(in)
+			if err != nil {
+				return nil, err
+			}
+			if t != nil {
+				return []*goTensor{t, {data: []float32{float32(len(args))}}}, nil
+			}
+			args = append(args, t)
+			continue
+		}
+		t, err := eval(in)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			return []*goTensor{nil, nil}, nil
+		}
+		args = append(args, t)
+	}
+	arg := func(i int) (*goTensor, error) {
+		if i >= len(args) {
+			return nil, fmt.Errorf("expected %d inputs, got %d", i+1, len(args))
+		}
+		return args[i], nil
+	}
+	one := func(t *goTensor, err error) ([]*goTensor, error) {
+		if err != nil {
+			return nil, err
+		}
+		return []*goTensor{t}, nil
+	}
+
+	switch n.op {
+	case "Placeholder":
+		if n.name == g.input {
+			return []*goTensor{input}, nil
+		}
+		if strings.HasSuffix(n.name, learningPhase) {
+			return []*goTensor{{data: []float32{0}}}, nil
+		}
+		return nil, fmt.Errorf("placeholder is not fed")
+	case "PlaceholderWithDefault":
+		if n.name == g.input {
+			return []*goTensor{input}, nil
+		}
+		return one(arg(0))
+	case "Const":
+		return one(n.tensor())
+	case "Identity", "StopGradient", "Snapshot", "Cast":
+		return one(arg(0))
+	case "Merge":
+		return nil, fmt.Errorf("no input was computed")
+	case "Switch":
+		data, err := arg(0)
+		if err != nil {
+			return nil, err
+		}
+		pred, err := arg(1)
+		if err != nil {
+			return nil, err
+		}
+		if len(pred.data) != 1 {
+			return nil, fmt.Errorf("predicate is not a scalar")
+		}
+		if pred.data[0] != 0 {
+			return []*goTensor{nil, data}, nil
+		}
+		return []*goTensor{data, nil}, nil
+
+	case "Conv2D", "DepthwiseConv2dNative":
+		if err := g.checkNHWC(n); err != nil {
+			return nil, err
+		}
+		strides, same, err := g.window(n, "strides")
+		if err != nil {
+			return nil, err
+		}
+		if d, _ := n.attrInts("dilations"); len(d) == 4 && (d[1] != 1 || d[2] != 1) {
+			return nil, fmt.Errorf("dilations %v are not supported", d)
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(args))
+		}
+		if n.op == "Conv2D" {
+			return one(conv2d(args[0], args[1], strides[1], strides[2], same))
+		}
+		return one(depthwiseConv2d(args[0], args[1], strides[1], strides[2], same))
+	case "MaxPool", "AvgPool":
+		if err := g.checkNHWC(n); err != nil {
+			return nil, err
+		}
+		strides, same, err := g.window(n, "strides")
+		if err != nil {
+			return nil, err
+		}
+		ksize, err := n.attrInts("ksize")
+		if err != nil || len(ksize) != 4 {
+			return nil, fmt.Errorf("invalid ksize %v", ksize)
+		}
+		x, err := arg(0)
+		if err != nil {
+			return nil, err
+		}
+		return one(pool2d(x, ksize[1], ksize[2], strides[1], strides[2], same, n.op == "AvgPool"))
+	case "BiasAdd":
+		if err := g.checkNHWC(n); err != nil {
+			return nil, err
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(args))
+		}
+		return one(biasAdd(args[0].clone(), args[1]))
+	case "FusedBatchNorm", "FusedBatchNormV2", "FusedBatchNormV3":
+		if err := g.checkNHWC(n); err != nil {
+			return nil, err
+		}
+		if len(args) != 5 {
+			return nil, fmt.Errorf("expected 5 inputs, got %d", len(args))
+		}
+		eps, err := n.attrFloat("epsilon", 0.0001)
+		if err != nil {
+			return nil, err
+		}
+		return one(batchNorm(args[0], args[1], args[2], args[3], args[4], eps))
+
+	case "Add", "AddV2", "Sub", "Mul", "RealDiv", "Maximum", "Minimum":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(args))
+		}
+		return one(binaryOp(n.op, args[0], args[1]))
+	case "Relu", "Relu6", "Sigmoid", "Tanh", "Rsqrt", "Sqrt":
+		x, err := arg(0)
+		if err != nil {
+			return nil, err
+		}
+		return one(unaryOp(n.op, x), nil)
+	case "Softmax":
+		x, err := arg(0)
+		if err != nil {
+			return nil, err
+		}
+		return one(softmax(x.clone()), nil)
+	case "MatMul":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(args))
+		}
+		ta, err := n.attrBool("transpose_a")
+		if err != nil {
+			return nil, err
+		}
+		tb, err := n.attrBool("transpose_b")
+		if err != nil {
+			return nil, err
+		}
+		return one(matMul(args[0], args[1], ta, tb))
+	case "Mean", "Prod", "Max", "Sum":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(args))
+		}
+		keep, err := n.attrBool("keep_dims")
+		if err != nil {
+			return nil, err
+		}
+		return one(reduce(n.op, args[0], args[1].ints(), keep))
+
+	case "Reshape":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(args))
+		}
+		return one(reshape(args[0], args[1].ints()))
+	case "Squeeze":
+		x, err := arg(0)
+		if err != nil {
+			return nil, err
+		}
+		dims, err := n.attrInts("squeeze_dims")
+		if err != nil {
+			return nil, err
+		}
+		return one(squeeze(x, dims))
+	case "Pad":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(args))
+		}
+		return one(pad(args[0], args[1].ints()))
+	case "Shape":
+		x, err := arg(0)
+		if err != nil {
+			return nil, err
+		}
+		t := newGoTensor(len(x.shape))
+		for i, d := range x.shape {
+			t.data[i] = float32(d)
+		}
+		return []*goTensor{t}, nil
+	case "Pack":
+		t := newGoTensor(len(args))
+		for i, a := range args {
+			if len(a.data) != 1 {
+				return nil, fmt.Errorf("only scalars can be packed")
+			}
+			t.data[i] = a.data[0]
+		}
+		return []*goTensor{t}, nil
+	case "ConcatV2":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("expected at least 2 inputs, got %d", len(args))
+		}
+		return one(concat(args[:len(args)-1], int(args[len(args)-1].data[0])))
+	case "StridedSlice":
+		if len(args) != 4 {
+			return nil, fmt.Errorf("expected 4 inputs, got %d", len(args))
+		}
+		return one(g.stridedSlice(n, args[0], args[1].ints(), args[2].ints(), args[3].ints()))
+	}
+	return nil, fmt.Errorf("operation is not supported by the Go backend")
+}
+
+func (g *goGraph) checkNHWC(n *graphNode) error {
+	format, err := n.attrString("data_format")
+	if err != nil {
+		return err
+	}
+	if format != "" && format != "NHWC" {
+		return fmt.Errorf("data format %s is not supported", format)
+	}
+	return nil
+}
+
+// window returns the strides and padding of a convolution or pooling node
+func (g *goGraph) window(n *graphNode, attr string) (strides []int, same bool, err error) {
+	if strides, err = n.attrInts(attr); err != nil {
+		return nil, false, err
+	}
+	if len(strides) != 4 {
+		return nil, false, fmt.Errorf("invalid %s %v", attr, strides)
+	}
+	padding, err := n.attrString("padding")
+	if err != nil {
+		return nil, false, err
+	}
+	switch padding {
+	case "SAME":
+		return strides, true, nil
+	case "VALID":
+		return strides, false, nil
+	}
+	return nil, false, fmt.Errorf("padding %q is not supported", padding)
+}
+
+// stridedSlice slices a vector, as used on the shapes of Keras layers
+func (g *goGraph) stridedSlice(n *graphNode, x *goTensor, begin, end, strides []int) (*goTensor, error) {
+	masks := map[string]int{}
+	for _, name := range []string{"begin_mask", "end_mask", "ellipsis_mask", "new_axis_mask", "shrink_axis_mask"} {
+		v, err := n.attrInt(name, 0)
+		if err != nil {
+			return nil, err
+		}
+		masks[name] = v
+	}
+	if len(x.shape) != 1 || len(begin) != 1 || masks["ellipsis_mask"] != 0 || masks["new_axis_mask"] != 0 {
+		return nil, fmt.Errorf("only vectors can be sliced")
+	}
+	size := x.shape[0]
+	b, e, s := begin[0], end[0], strides[0]
+	if s <= 0 {
+		return nil, fmt.Errorf("stride %d is not supported", s)
+	}
+	if b < 0 {
+		b += size
+	}
+	if e < 0 {
+		e += size
+	}
+	if masks["begin_mask"]&1 != 0 {
+		b = 0
+	}
+	if masks["end_mask"]&1 != 0 {
+		e = size
+	}
+	if masks["shrink_axis_mask"]&1 != 0 {
+		e = b + 1
+	}
+	if b < 0 || b > size || e > size {
+		return nil, fmt.Errorf("slice [%d:%d] out of range of %d", b, e, size)
+	}
+	t := &goTensor{shape: []int{0}}
+	for i := b; i < e; i += s {
+		t.data = append(t.data, x.data[i])
+	}
+	t.shape[0] = len(t.data)
+	if masks["shrink_axis_mask"]&1 != 0 {
+		t.shape = nil
+	}
+	return t, nil
+}
+
+func (t *goTensor) clone() *goTensor {
+	c := &goTensor{shape: append([]int(nil), t.shape...), data: make([]float32, len(t.data))}
+	copy(c.data, t.data)
+	return c
+}
+
+// ints returns the values of an integer tensor
+func (t *goTensor) ints() []int {
+	res := make([]int, len(t.data))
+	for i, v := range t.data {
+		res[i] = int(v)
+	}
+	return res
+}
+
+// binaryOp applies an element wise operation with numpy style broadcasting
+func binaryOp(op string, a, b *goTensor) (*goTensor, error) {
+	var fn func(x, y float32) float32
+	switch op {
+	case "Add", "AddV2":
+		fn = func(x, y float32) float32 { return x + y }
+	case "Sub":
+		fn = func(x, y float32) float32 { return x - y }
+	case "Mul":
+		fn = func(x, y float32) float32 { return x * y }
+	case "RealDiv":
+		fn = func(x, y float32) float32 { return x / y }
+	case "Maximum":
+		fn = maxFloat
+	case "Minimum":
+		fn = minFloat
+	default:
+		return nil, fmt.Errorf("unknown operation %s", op)
+	}
+
+	rank := len(a.shape)
+	if len(b.shape) > rank {
+		rank = len(b.shape)
+	}
+	shape := make([]int, rank)
+	as, bs := make([]int, rank), make([]int, rank) // strides, 0 for broadcast dimensions
+	sa, sb := 1, 1
+	for i := rank - 1; i >= 0; i-- {
+		da, db := 1, 1
+		if j := i - rank + len(a.shape); j >= 0 {
+			da = a.shape[j]
+		}
+		if j := i - rank + len(b.shape); j >= 0 {
+			db = b.shape[j]
+		}
+		switch {
+		case da == db, db == 1:
+			shape[i] = da
+		case da == 1:
+			shape[i] = db
+		default:
+			return nil, fmt.Errorf("shapes %v and %v cannot be broadcast", a.shape, b.shape)
+		}
+		if da != 1 {
+			as[i] = sa
+		}
+		if db != 1 {
+			bs[i] = sb
+		}
+		sa *= da
+		sb *= db
+	}
+
+	out := newGoTensor(shape...)
+	idx := make([]int, rank)
+	ia, ib := 0, 0
+	for i := range out.data {
+		out.data[i] = fn(a.data[ia], b.data[ib])
+		for d := rank - 1; d >= 0; d-- {
+			idx[d]++
+			ia += as[d]
+			ib += bs[d]
+			if idx[d] < shape[d] {
+				break
+			}
+			ia -= as[d] * shape[d]
+			ib -= bs[d] * shape[d]
+			idx[d] = 0
+		}
+	}
+	return out, nil
+}
+
+func unaryOp(op string, x *goTensor) *goTensor {
+	out := x.clone()
+	for i, v := range out.data {
+		switch op {
+		case "Relu":
+			v = maxFloat(v, 0)
+		case "Relu6":
+			v = minFloat(maxFloat(v, 0), 6)
+		case "Sigmoid":
+			v = float32(1 / (1 + math.Exp(-float64(v))))
+		case "Tanh":
+			v = float32(math.Tanh(float64(v)))
+		case "Rsqrt":
+			v = float32(1 / math.Sqrt(float64(v)))
+		case "Sqrt":
+			v = float32(math.Sqrt(float64(v)))
+		}
+		out.data[i] = v
+	}
+	return out
+}
+
+// batchNorm normalizes the last dimension of x with the moving mean and variance
+func batchNorm(x, scale, offset, mean, variance *goTensor, eps float32) (*goTensor, error) {
+	c := x.dim(-1)
+	for _, t := range []*goTensor{scale, offset, mean, variance} {
+		if len(t.data) != c {
+			return nil, fmt.Errorf("batch norm parameters of shape %v do not match input shape %v", t.shape, x.shape)
+		}
+	}
+	mul, add := make([]float32, c), make([]float32, c)
+	for i := range mul {
+		mul[i] = scale.data[i] / float32(math.Sqrt(float64(variance.data[i]+eps)))
+		add[i] = offset.data[i] - mean.data[i]*mul[i]
+	}
+	out := x.clone()
+	for i, v := range out.data {
+		out.data[i] = v*mul[i%c] + add[i%c]
+	}
+	return out, nil
+}
+
+// reduce reduces x along axes
+func reduce(op string, x *goTensor, axes []int, keep bool) (*goTensor, error) {
+	reduced := make([]bool, len(x.shape))
+	for _, a := range axes {
+		if a < 0 {
+			a += len(x.shape)
+		}
+		if a < 0 || a >= len(x.shape) {
+			return nil, fmt.Errorf("axis %d out of range of shape %v", a, x.shape)
+		}
+		reduced[a] = true
+	}
+	var shape, kept []int
+	for i, d := range x.shape {
+		if reduced[i] {
+			kept = append(kept, 1)
+			continue
+		}
+		shape = append(shape, d)
+		kept = append(kept, d)
+	}
+	if keep {
+		shape = kept
+	}
+
+	out := newGoTensor(shape...)
+	counts := make([]int, len(out.data))
+	for i := range out.data {
+		switch op {
+		case "Prod":
+			out.data[i] = 1
+		case "Max":
+			out.data[i] = float32(math.Inf(-1))
+		}
+	}
+	idx := make([]int, len(x.shape))
+	for _, v := range x.data {
+		o := 0
+		for d, n := range kept {
+			if !reduced[d] {
+				o = o*n + idx[d]
+			}
+		}
+		switch op {
+		case "Prod":
+			out.data[o] *= v
+		case "Max":
+			out.data[o] = maxFloat(out.data[o], v)
+		default:
+			out.data[o] += v
+		}
+		counts[o]++
+		for d := len(idx) - 1; d >= 0; d-- {
+			if idx[d]++; idx[d] < x.shape[d] {
+				break
+			}
+			idx[d] = 0
+		}
+	}
+	if op == "Mean" {
+		for i := range out.data {
+			if counts[i] > 0 {
+				out.data[i] /= float32(counts[i])
+			}
+		}
+	}
+	return out, nil
+}
+
+func reshape(x *goTensor, shape []int) (*goTensor, error) {
+	infer, size := -1, 1
+	for i, d := range shape {
+		if d == -1 {
+			if infer >= 0 {
+				return nil, fmt.Errorf("reshape: more than one -1 in %v", shape)
+			}
+			infer = i
+			continue
+		}
+		size *= d
+	}
+	shape = append([]int(nil), shape...)
+	if infer >= 0 && size > 0 {
+		shape[infer] = len(x.data) / size
+	}
+	if shapeSize(shape) != len(x.data) {
+		return nil, fmt.Errorf("reshape: cannot reshape %v to %v", x.shape, shape)
+	}
+	return &goTensor{shape: shape, data: x.data}, nil
+}
+
+func squeeze(x *goTensor, dims []int) (*goTensor, error) {
+	drop := map[int]bool{}
+	for _, d := range dims {
+		if d < 0 {
+			d += len(x.shape)
+		}
+		drop[d] = true
+	}
+	var shape []int
+	for i, d := range x.shape {
+		if d == 1 && (len(dims) == 0 || drop[i]) {
+			continue
+		}
+		if drop[i] {
+			return nil, fmt.Errorf("squeeze: dimension %d of %v is not 1", i, x.shape)
+		}
+		shape = append(shape, d)
+	}
+	return &goTensor{shape: shape, data: x.data}, nil
+}
+
+// pad zero pads a [N,H,W,C] tensor with paddings [4,2]
+func pad(x *goTensor, paddings []int) (*goTensor, error) {
+	if len(x.shape) != 4 || len(paddings) != 8 || paddings[0] != 0 || paddings[1] != 0 || paddings[6] != 0 || paddings[7] != 0 {
+		return nil, fmt.Errorf("pad: only spatial padding of NHWC tensors is supported")
+	}
+	n, h, w, c := x.shape[0], x.shape[1], x.shape[2], x.shape[3]
+	top, left := paddings[2], paddings[4]
+	oh, ow := h+top+paddings[3], w+left+paddings[5]
+	out := newGoTensor(n, oh, ow, c)
+	for b := 0; b < n; b++ {
+		for y := 0; y < h; y++ {
+			copy(out.data[((b*oh+y+top)*ow+left)*c:], x.data[(b*h+y)*w*c:][:w*c])
+		}
+	}
+	return out, nil
+}
+
+func concat(xs []*goTensor, axis int) (*goTensor, error) {
+	rank := len(xs[0].shape)
+	if axis < 0 {
+		axis += rank
+	}
+	if axis < 0 || axis >= rank {
+		return nil, fmt.Errorf("concat: axis %d out of range", axis)
+	}
+	shape := append([]int(nil), xs[0].shape...)
+	shape[axis] = 0
+	for _, x := range xs {
+		if len(x.shape) != rank {
+			return nil, fmt.Errorf("concat: shapes %v and %v do not match", xs[0].shape, x.shape)
+		}
+		for i := range shape {
+			if i != axis && x.shape[i] != shape[i] {
+				return nil, fmt.Errorf("concat: shapes %v and %v do not match", xs[0].shape, x.shape)
+			}
+		}
+		shape[axis] += x.shape[axis]
+	}
+	outer := shapeSize(shape[:axis])
+	out := &goTensor{shape: shape}
+	for o := 0; o < outer; o++ {
+		for _, x := range xs {
+			inner := shapeSize(x.shape[axis:])
+			out.data = append(out.data, x.data[o*inner:(o+1)*inner]...)
+		}
+	}
+	return out, nil
+}
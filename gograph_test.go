@@ -0,0 +1,158 @@
+package tfimage
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// testGraphNode encodes a NodeDef with attrs of encoded AttrValues
+func testGraphNode(name, op string, inputs []string, attrs map[string][]byte) []byte {
+	var b []byte
+	b = appendBytes(b, nodeDefName, []byte(name))
+	b = appendBytes(b, nodeDefOp, []byte(op))
+	for _, in := range inputs {
+		b = appendBytes(b, nodeDefInput, []byte(in))
+	}
+	for k, v := range attrs {
+		entry := appendBytes(appendBytes(nil, mapKey, []byte(k)), mapValue, v)
+		b = appendBytes(b, nodeDefAttr, entry)
+	}
+	return b
+}
+
+// testConst encodes a float32 or int32 Const node
+func testConst(name string, dtype int, values []float32, shape ...int) []byte {
+	var dims, content []byte
+	for _, d := range shape {
+		dims = appendBytes(dims, shapeDim, appendInt(nil, dimSize, int64(d)))
+	}
+	for _, v := range values {
+		bits := math.Float32bits(v)
+		if dtype == dtInt32 {
+			bits = uint32(int32(v))
+		}
+		content = appendUint32(content, bits)
+	}
+	var tensor []byte
+	tensor = appendInt(tensor, tensorDType, int64(dtype))
+	tensor = appendBytes(tensor, tensorShape, dims)
+	tensor = appendBytes(tensor, tensorContent, content)
+	return testGraphNode(name, "Const", nil, map[string][]byte{"value": appendBytes(nil, attrValueTensor, tensor)})
+}
+
+func attrInts(v ...int) []byte {
+	var list []byte
+	for _, i := range v {
+		list = appendInt(list, attrValueI, int64(i))
+	}
+	return appendBytes(nil, attrValueList, list)
+}
+
+func attrString(s string) []byte { return appendBytes(nil, attrValueS, []byte(s)) }
+
+func attrFloat(f float32) []byte {
+	return appendUint32(appendTag(nil, attrValueF, wireFixed32), math.Float32bits(f))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var p [4]byte
+	binary.LittleEndian.PutUint32(p[:], v)
+	return append(b, p[:]...)
+}
+
+// testNIMAGraph returns a GraphDef with the operations of the Keras MobileNet
+// graph of the NIMA models, on a 3 channel input:
+//
+//	conv 1x1 [R, -R] + 0.5, batch norm (x-0.5)*2, learning phase dropout,
+//	relu6, depthwise 1x1 [1, 2], global average pool, dense [2,3], softmax
+func testNIMAGraph() []byte {
+	window := map[string][]byte{"strides": attrInts(1, 1, 1, 1), "padding": attrString("VALID"), "data_format": attrString("NHWC")}
+	nodes := [][]byte{
+		testGraphNode(nimaInput, "Placeholder", nil, nil),
+		testGraphNode("batch_normalization_1/keras_learning_phase", "Placeholder", nil, nil),
+		testConst("conv1/kernel", dtFloat, []float32{1, -1, 0, 0, 0, 0}, 1, 1, 3, 2),
+		testGraphNode("conv1/convolution", "Conv2D", []string{nimaInput, "conv1/kernel"}, window),
+		testConst("conv1/bias", dtFloat, []float32{0.5, 0.5}, 2),
+		testGraphNode("conv1/BiasAdd", "BiasAdd", []string{"conv1/convolution", "conv1/bias"}, nil),
+		testConst("bn/gamma", dtFloat, []float32{2, 2}, 2),
+		testConst("bn/beta", dtFloat, []float32{0, 0}, 2),
+		testConst("bn/moving_mean", dtFloat, []float32{0.5, 0.5}, 2),
+		testConst("bn/moving_variance", dtFloat, []float32{1, 1}, 2),
+		testGraphNode("bn/FusedBatchNorm", "FusedBatchNorm",
+			[]string{"conv1/BiasAdd", "bn/gamma", "bn/beta", "bn/moving_mean", "bn/moving_variance"},
+			map[string][]byte{"epsilon": attrFloat(0)}),
+		// the training branch of the learning phase must not be evaluated
+		testGraphNode("dropout/Switch", "Switch", []string{"bn/FusedBatchNorm", "batch_normalization_1/keras_learning_phase"}, nil),
+		testGraphNode("dropout/train", "Unsupported", []string{"dropout/Switch:1"}, nil),
+		testGraphNode("dropout/Merge", "Merge", []string{"dropout/train", "dropout/Switch"}, nil),
+		testGraphNode("relu/Relu6", "Relu6", []string{"dropout/Merge", "^dropout/Switch"}, nil),
+		testConst("dw/depthwise_kernel", dtFloat, []float32{1, 2}, 1, 1, 2, 1),
+		testGraphNode("dw/depthwise", "DepthwiseConv2dNative", []string{"relu/Relu6", "dw/depthwise_kernel"}, window),
+		testConst("pool/axes", dtInt32, []float32{1, 2}, 2),
+		testGraphNode("pool/Mean", "Mean", []string{"dw/depthwise", "pool/axes"}, nil),
+		testConst("flatten/shape", dtInt32, []float32{1, -1}, 2),
+		testGraphNode("flatten/Reshape", "Reshape", []string{"pool/Mean", "flatten/shape"}, nil),
+		testConst("dense_1/kernel", dtFloat, []float32{1, 0, -1, 0, 1, 0}, 2, 3),
+		testGraphNode("dense_1/MatMul", "MatMul", []string{"flatten/Reshape", "dense_1/kernel"}, nil),
+		testGraphNode(nimaOutput, "Softmax", []string{"dense_1/MatMul"}, nil),
+	}
+	var def []byte
+	for _, n := range nodes {
+		def = appendBytes(def, graphDefNode, n)
+	}
+	return def
+}
+
+func TestGoGraphNIMAOps(t *testing.T) {
+	m, err := GoBackend.LoadAestheticsModel(testNIMAGraph(), ModelOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// R is 0, 1, 2, 3; G and B are ignored by the convolution
+	img := &ImageData{Width: 2, Height: 2, Pix: []float32{0, 9, 9, 1, 9, 9, 2, 9, 9, 3, 9, 9}}
+	scores, err := m.Scores(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// conv [R+0.5, 0.5-R], batch norm [2R, -2R], relu6 [0 2 4 6, 0],
+	// depthwise and average pool [3, 0], dense [3, 0, -3]
+	e := math.Exp(3)
+	sum := e + 1 + 1/e
+	want := []float32{float32(e / sum), float32(1 / sum), float32(1 / e / sum)}
+	if len(scores) != len(want) {
+		t.Fatalf("scores %v, want %v", scores, want)
+	}
+	for i := range want {
+		if math.Abs(float64(scores[i]-want[i])) > nnTolerance {
+			t.Fatalf("scores %v, want %v", scores, want)
+		}
+	}
+
+	m.Close()
+	if _, err = m.Scores(img); err != errModelClosed {
+		t.Errorf("closed model: error %v, want %v", err, errModelClosed)
+	}
+}
+
+func TestGoGraphErrors(t *testing.T) {
+	if _, err := GoBackend.LoadAestheticsModel(readModel(t, "mtcnn_1.14.pb"), ModelOptions{}); err == nil {
+		t.Error("MTCNN graph as NIMA: expected an error")
+	}
+
+	g, err := parseGraphDef(testNIMAGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := &goGraph{def: g, input: nimaInput, output: nimaOutput}
+	if _, err = graph.run(seq(0, 1, 2, 2, 2)); err == nil {
+		t.Error("2 channel input: expected an error")
+	}
+	graph.output = "dropout/train"
+	if _, err = graph.run(seq(0, 1, 2, 2, 3)); err == nil {
+		t.Error("untaken branch as output: expected an error")
+	}
+}
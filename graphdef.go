@@ -0,0 +1,414 @@
+package tfimage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// GraphDef proto field numbers from tensorflow/core/framework/graph.proto,
+// node_def.proto, attr_value.proto, tensor.proto and tensor_shape.proto
+const (
+	graphDefNode = 1
+
+	nodeDefName  = 1
+	nodeDefOp    = 2
+	nodeDefInput = 3
+	nodeDefAttr  = 5
+
+	attrValueList   = 1
+	attrValueS      = 2
+	attrValueI      = 3
+	attrValueF      = 4
+	attrValueB      = 5
+	attrValueType   = 6
+	attrValueShape  = 7
+	attrValueTensor = 8
+
+	tensorDType     = 1
+	tensorShape     = 2
+	tensorContent   = 4
+	tensorFloatVal  = 5
+	tensorDoubleVal = 6
+	tensorIntVal    = 7
+	tensorInt64Val  = 10
+	tensorBoolVal   = 11
+
+	shapeDim         = 2
+	shapeUnknownRank = 3
+	dimSize          = 1
+)
+
+// tensorflow DataType values from tensorflow/core/framework/types.proto
+const (
+	dtFloat  = 1
+	dtDouble = 2
+	dtInt32  = 3
+	dtUint8  = 4
+	dtInt16  = 5
+	dtInt8   = 6
+	dtInt64  = 9
+	dtBool   = 10
+)
+
+// graphNode is a NodeDef of a GraphDef. Attributes are decoded when used.
+type graphNode struct {
+	name, op string
+	inputs   []string
+	attrs    map[string][]byte
+}
+
+// graphDef is a decoded frozen GraphDef
+type graphDef struct {
+	nodes  []*graphNode
+	byName map[string]*graphNode
+}
+
+// parseGraphDef decodes a serialized GraphDef
+func parseGraphDef(b []byte) (*graphDef, error) {
+	g := &graphDef{byName: map[string]*graphNode{}}
+	err := decodeProto(b, func(f protoField) error {
+		if f.Num != graphDefNode {
+			return nil
+		}
+		n := &graphNode{attrs: map[string][]byte{}}
+		err := decodeProto(f.Bytes, func(f protoField) error {
+			switch f.Num {
+			case nodeDefName:
+				n.name = string(f.Bytes)
+			case nodeDefOp:
+				n.op = string(f.Bytes)
+			case nodeDefInput:
+				n.inputs = append(n.inputs, string(f.Bytes))
+			case nodeDefAttr:
+				k, v, err := decodeMapEntry(f.Bytes)
+				n.attrs[k] = v
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		g.nodes = append(g.nodes, n)
+		g.byName[n.name] = n
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("graph def: %v", err)
+	}
+	if len(g.nodes) == 0 {
+		return nil, fmt.Errorf("graph def: no nodes found")
+	}
+	return g, nil
+}
+
+// node returns the node named name, accepting tensor names such as "op:0"
+func (g *graphDef) node(name string) (*graphNode, error) {
+	name = strings.TrimPrefix(name, "^")
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		name = name[:i]
+	}
+	n, ok := g.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("graph def: node %q not found", name)
+	}
+	return n, nil
+}
+
+// constant returns the value of the Const node named name, following Identity nodes
+func (g *graphDef) constant(name string) (*goTensor, error) {
+	n, err := g.node(name)
+	if err != nil {
+		return nil, err
+	}
+	for n.op == "Identity" && len(n.inputs) > 0 {
+		if n, err = g.node(n.inputs[0]); err != nil {
+			return nil, err
+		}
+	}
+	if n.op != "Const" {
+		return nil, fmt.Errorf("graph def: node %q is a %s, expected Const", n.name, n.op)
+	}
+	return n.tensor()
+}
+
+// attr calls fn for the fields of the AttrValue name, and reports whether it exists
+func (n *graphNode) attr(name string, fn func(f protoField) error) (bool, error) {
+	b, ok := n.attrs[name]
+	if !ok {
+		return false, nil
+	}
+	if err := decodeProto(b, fn); err != nil {
+		return true, fmt.Errorf("node %q attribute %q: %v", n.name, name, err)
+	}
+	return true, nil
+}
+
+func (n *graphNode) attrInt(name string, def int) (int, error) {
+	v := def
+	_, err := n.attr(name, func(f protoField) error {
+		if f.Num == attrValueI {
+			v = int(int64(f.Varint))
+		}
+		return nil
+	})
+	return v, err
+}
+
+func (n *graphNode) attrBool(name string) (bool, error) {
+	var v bool
+	_, err := n.attr(name, func(f protoField) error {
+		if f.Num == attrValueB {
+			v = f.Varint != 0
+		}
+		return nil
+	})
+	return v, err
+}
+
+func (n *graphNode) attrFloat(name string, def float32) (float32, error) {
+	v := def
+	_, err := n.attr(name, func(f protoField) error {
+		if f.Num == attrValueF && f.Wire == wireFixed32 {
+			v = math.Float32frombits(binary.LittleEndian.Uint32(f.Bytes))
+		}
+		return nil
+	})
+	return v, err
+}
+
+func (n *graphNode) attrString(name string) (string, error) {
+	var v string
+	_, err := n.attr(name, func(f protoField) error {
+		if f.Num == attrValueS {
+			v = string(f.Bytes)
+		}
+		return nil
+	})
+	return v, err
+}
+
+// attrInts returns the integers of a list attribute
+func (n *graphNode) attrInts(name string) ([]int, error) {
+	var v []int
+	_, err := n.attr(name, func(f protoField) error {
+		if f.Num != attrValueList {
+			return nil
+		}
+		return decodeProto(f.Bytes, func(f protoField) error {
+			if f.Num == attrValueI {
+				return decodeVarints(f, func(i uint64) { v = append(v, int(int64(i))) })
+			}
+			return nil
+		})
+	})
+	return v, err
+}
+
+// outputType returns the data type of the outputs of n, or 0 when the GraphDef does not record it
+func (n *graphNode) outputType() (int, error) {
+	for _, name := range []string{"dtype", "T"} {
+		dtype := 0
+		found, err := n.attr(name, func(f protoField) error {
+			if f.Num == attrValueType {
+				dtype = int(f.Varint)
+			}
+			return nil
+		})
+		if found || err != nil {
+			return dtype, err
+		}
+	}
+	return 0, nil
+}
+
+// outputRank returns the rank of output index of n, from the shape of a Placeholder or
+// the "_output_shapes" attribute, or -1 when the GraphDef does not record it
+func (n *graphNode) outputRank(index int) (int, error) {
+	var shapes [][]byte
+	if n.op == "Placeholder" {
+		if _, err := n.attr("shape", func(f protoField) error {
+			if f.Num == attrValueShape {
+				shapes = append(shapes, f.Bytes)
+			}
+			return nil
+		}); err != nil {
+			return -1, err
+		}
+	}
+	if len(shapes) == 0 {
+		if _, err := n.attr("_output_shapes", func(f protoField) error {
+			if f.Num != attrValueList {
+				return nil
+			}
+			return decodeProto(f.Bytes, func(f protoField) error {
+				if f.Num == attrValueShape {
+					shapes = append(shapes, f.Bytes)
+				}
+				return nil
+			})
+		}); err != nil {
+			return -1, err
+		}
+		if index >= len(shapes) {
+			return -1, nil
+		}
+		shapes = shapes[index:]
+	}
+	if len(shapes) == 0 {
+		return -1, nil
+	}
+	rank, unknown := 0, false
+	err := decodeProto(shapes[0], func(f protoField) error {
+		switch f.Num {
+		case shapeDim:
+			rank++
+		case shapeUnknownRank:
+			unknown = f.Varint != 0
+		}
+		return nil
+	})
+	if unknown {
+		return -1, err
+	}
+	return rank, err
+}
+
+// tensor returns the "value" attribute of a Const node
+func (n *graphNode) tensor() (*goTensor, error) {
+	var t *goTensor
+	found, err := n.attr("value", func(f protoField) error {
+		if f.Num != attrValueTensor {
+			return nil
+		}
+		var err error
+		t, err = decodeTensor(f.Bytes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found || t == nil {
+		return nil, fmt.Errorf("node %q has no tensor value", n.name)
+	}
+	return t, nil
+}
+
+// decodeTensor decodes a numeric TensorProto into a goTensor
+func decodeTensor(b []byte) (*goTensor, error) {
+	var dtype int
+	var shape []int
+	var content []byte
+	var values []float32
+	err := decodeProto(b, func(f protoField) error {
+		switch f.Num {
+		case tensorDType:
+			dtype = int(f.Varint)
+		case tensorShape:
+			return decodeProto(f.Bytes, func(f protoField) error {
+				if f.Num != shapeDim {
+					return nil
+				}
+				size := 0
+				err := decodeProto(f.Bytes, func(f protoField) error {
+					if f.Num == dimSize {
+						size = int(int64(f.Varint))
+					}
+					return nil
+				})
+				shape = append(shape, size)
+				return err
+			})
+		case tensorContent:
+			content = f.Bytes
+		case tensorFloatVal:
+			return decodeFixed32(f, func(v uint32) { values = append(values, math.Float32frombits(v)) })
+		case tensorDoubleVal:
+			if f.Wire == wireFixed64 {
+				values = append(values, float32(math.Float64frombits(binary.LittleEndian.Uint64(f.Bytes))))
+				return nil
+			}
+			for p := f.Bytes; len(p) >= 8; p = p[8:] {
+				values = append(values, float32(math.Float64frombits(binary.LittleEndian.Uint64(p))))
+			}
+		case tensorIntVal, tensorInt64Val, tensorBoolVal:
+			return decodeVarints(f, func(v uint64) { values = append(values, float32(int64(v))) })
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := newGoTensor(shape...)
+	if len(content) > 0 {
+		size, read := 0, func(p []byte) float32 { return 0 }
+		switch dtype {
+		case dtFloat:
+			size, read = 4, func(p []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(p)) }
+		case dtDouble:
+			size, read = 8, func(p []byte) float32 { return float32(math.Float64frombits(binary.LittleEndian.Uint64(p))) }
+		case dtInt32:
+			size, read = 4, func(p []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(p))) }
+		case dtInt64:
+			size, read = 8, func(p []byte) float32 { return float32(int64(binary.LittleEndian.Uint64(p))) }
+		case dtInt16:
+			size, read = 2, func(p []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(p))) }
+		case dtInt8:
+			size, read = 1, func(p []byte) float32 { return float32(int8(p[0])) }
+		case dtUint8, dtBool:
+			size, read = 1, func(p []byte) float32 { return float32(p[0]) }
+		default:
+			return nil, fmt.Errorf("tensor: unsupported data type %d", dtype)
+		}
+		if len(content) != len(t.data)*size {
+			return nil, fmt.Errorf("tensor: %d bytes of content for shape %v", len(content), shape)
+		}
+		for i := range t.data {
+			t.data[i] = read(content[i*size:])
+		}
+		return t, nil
+	}
+
+	// Repeated values shorter than the shape are filled with the last value
+	if len(values) > len(t.data) {
+		return nil, fmt.Errorf("tensor: %d values for shape %v", len(values), shape)
+	}
+	copy(t.data, values)
+	if len(values) > 0 {
+		for i := len(values); i < len(t.data); i++ {
+			t.data[i] = values[len(values)-1]
+		}
+	}
+	return t, nil
+}
+
+// decodeVarints calls fn for the values of a packed or unpacked repeated varint field
+func decodeVarints(f protoField, fn func(v uint64)) error {
+	if f.Wire == wireVarint {
+		fn(f.Varint)
+		return nil
+	}
+	for b := f.Bytes; len(b) > 0; {
+		v, n := readVarint(b)
+		if n == 0 {
+			return errProtoTruncated
+		}
+		fn(v)
+		b = b[n:]
+	}
+	return nil
+}
+
+// decodeFixed32 calls fn for the values of a packed or unpacked repeated fixed32 field
+func decodeFixed32(f protoField, fn func(v uint32)) error {
+	if len(f.Bytes)%4 != 0 {
+		return errProtoTruncated
+	}
+	for b := f.Bytes; len(b) > 0; b = b[4:] {
+		fn(binary.LittleEndian.Uint32(b))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package tfimage
+
+import (
+	"errors"
+	"image"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// testImages returns the sample images of the parity tests: a portrait,
+// its mirror image and a half size copy
+func testImages(t *testing.T) map[string]image.Image {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "grace_hopper.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]image.Image{
+		"grace_hopper":      img,
+		"grace_hopper_flip": imaging.FlipH(img),
+		"grace_hopper_half": imaging.Resize(img, img.Bounds().Dx()/2, 0, imaging.Linear),
+	}
+}
+
+// readModel reads a model file of the models directory
+func readModel(t *testing.T, name string) []byte {
+	t.Helper()
+	def, err := os.ReadFile(filepath.Join("models", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return def
+}
+
+// faceIoU returns the intersection over union of the boxes of two faces
+func faceIoU(a, b Face) float64 {
+	y1, x1 := math.Max(float64(a.box[0]), float64(b.box[0])), math.Max(float64(a.box[1]), float64(b.box[1]))
+	y2, x2 := math.Min(float64(a.box[2]), float64(b.box[2])), math.Min(float64(a.box[3]), float64(b.box[3]))
+	inter := math.Max(y2-y1, 0) * math.Max(x2-x1, 0)
+	area := func(f Face) float64 { return float64(f.box[2]-f.box[0]) * float64(f.box[3]-f.box[1]) }
+	union := area(a) + area(b) - inter
+	if union <= 0 {
+		return 0
+	}
+	return inter / union
+}
+
+// compareFaces checks that got has a face matching each face of want, with a box
+// IoU of at least minIoU and a probability within maxProbDiff
+func compareFaces(t *testing.T, name string, want, got []Face, minIoU, maxProbDiff float64) {
+	t.Helper()
+	if len(want) == 0 {
+		t.Errorf("%s: no faces detected by the reference", name)
+	}
+	if len(got) != len(want) {
+		t.Errorf("%s: %d faces, want %d", name, len(got), len(want))
+	}
+	for i, w := range want {
+		best, bestIoU := -1, 0.0
+		for j, g := range got {
+			if iou := faceIoU(w, g); iou > bestIoU {
+				best, bestIoU = j, iou
+			}
+		}
+		if best < 0 || bestIoU < minIoU {
+			t.Errorf("%s: face %d %v has no match, best IoU %.3f < %.3f", name, i, w.box, bestIoU, minIoU)
+			continue
+		}
+		if d := math.Abs(float64(w.p - got[best].p)); d > maxProbDiff {
+			t.Errorf("%s: face %d probability %.4f, want %.4f ± %.4f", name, i, got[best].p, w.p, maxProbDiff)
+		}
+	}
+}
+
+// fakeAestheticsModel is an AestheticsModel that returns scores and records its calls.
+// When block is set, Scores signals started and waits for block to be closed.
+type fakeAestheticsModel struct {
+	scores []float32
+	err    error
+
+	mu      sync.Mutex
+	block   chan struct{}
+	started chan struct{}
+	sizes   []image.Point
+	closed  bool
+}
+
+func (m *fakeAestheticsModel) Scores(img *ImageData) ([]float32, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, errModelClosed
+	}
+	m.sizes = append(m.sizes, image.Pt(img.Width, img.Height))
+	block, started := m.block, m.started
+	m.mu.Unlock()
+
+	if block != nil {
+		started <- struct{}{}
+		<-block
+		if m.isClosed() {
+			return nil, errors.New("model closed during a call")
+		}
+	}
+	return m.scores, m.err
+}
+
+func (m *fakeAestheticsModel) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+}
+
+func (m *fakeAestheticsModel) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// fakeFaceModel is a FaceModel that returns faces and records the sizes of its images
+type fakeFaceModel struct {
+	faces  []Face
+	sizes  []image.Point
+	params []MTCNNParams
+	closed bool
+}
+
+func (m *fakeFaceModel) DetectFaces(img *ImageData, params MTCNNParams) ([]Face, error) {
+	if m.closed {
+		return nil, errModelClosed
+	}
+	m.sizes = append(m.sizes, image.Pt(img.Width, img.Height))
+	m.params = append(m.params, params)
+	return m.faces, nil
+}
+
+func (m *fakeFaceModel) Close() { m.closed = true }
@@ -0,0 +1,243 @@
+package tfimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// Homography - A 3x3 perspective transform in row major order. A point (x,y)
+// is mapped to ((H[0]x + H[1]y + H[2]) / w, (H[3]x + H[4]y + H[5]) / w)
+// with w = H[6]x + H[7]y + H[8].
+type Homography [9]float64
+
+// NewHomography - Create a new identity Homography
+func NewHomography() Homography {
+	return Homography{1, 0, 0, 0, 1, 0, 0, 0, 1}
+}
+
+// HomographyFromMatrix returns the Homography of an affine Matrix
+func HomographyFromMatrix(m Matrix) Homography {
+	return Homography{m.XX, m.XY, m.X0, m.YX, m.YY, m.Y0, 0, 0, 1}
+}
+
+// TransformPoint maps x and y through the Homography. Points on the line at
+// infinity are mapped to infinite coordinates.
+func (h Homography) TransformPoint(x, y float64) (tx, ty float64) {
+	w := h[6]*x + h[7]*y + h[8]
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w
+}
+
+// Multiply returns the Homography that applies h and then b, as Matrix.Multiply does
+func (h Homography) Multiply(b Homography) Homography {
+	var r Homography
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i*3+j] = b[i*3]*h[j] + b[i*3+1]*h[3+j] + b[i*3+2]*h[6+j]
+		}
+	}
+	return r
+}
+
+// Determinant returns the determinant of the Homography
+func (h Homography) Determinant() float64 {
+	return h[0]*(h[4]*h[8]-h[5]*h[7]) - h[1]*(h[3]*h[8]-h[5]*h[6]) + h[2]*(h[3]*h[7]-h[4]*h[6])
+}
+
+// Invert returns the inverse of the Homography, or ErrSingularMatrix when it has no inverse
+func (h Homography) Invert() (Homography, error) {
+	det := h.Determinant()
+	var norm float64
+	for _, v := range h {
+		norm = math.Max(norm, math.Abs(v))
+	}
+	if math.Abs(det) <= 1e-12*norm*norm*norm || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Homography{}, ErrSingularMatrix
+	}
+	inv := Homography{
+		h[4]*h[8] - h[5]*h[7], h[2]*h[7] - h[1]*h[8], h[1]*h[5] - h[2]*h[4],
+		h[5]*h[6] - h[3]*h[8], h[0]*h[8] - h[2]*h[6], h[2]*h[3] - h[0]*h[5],
+		h[3]*h[7] - h[4]*h[6], h[1]*h[6] - h[0]*h[7], h[0]*h[4] - h[1]*h[3],
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv.normalize(), nil
+}
+
+// normalize scales the Homography so that its last element is 1
+func (h Homography) normalize() Homography {
+	if h[8] == 0 {
+		return h
+	}
+	s := h[8]
+	for i := range h {
+		h[i] /= s
+	}
+	return h
+}
+
+// EstimateHomography fits the Homography that maps src to dst from four or more
+// correspondences, with the normalized direct linear transform. It returns the
+// root mean square distance between the transformed src points and dst.
+func EstimateHomography(src, dst []Point) (Homography, float64, error) {
+	if len(src) != len(dst) {
+		return Homography{}, 0, fmt.Errorf("homography: %d source points and %d destination points", len(src), len(dst))
+	}
+	if len(src) < 4 {
+		return Homography{}, 0, fmt.Errorf("homography: %d points, at least 4 are needed", len(src))
+	}
+	ts, _ := normalizePoints(src)
+	td, tdInv := normalizePoints(dst)
+
+	// Normal equations of the linear system with H[8] = 1
+	var ata [8][8]float64
+	var atb [8]float64
+	add := func(row [8]float64, b float64) {
+		for i := 0; i < 8; i++ {
+			for j := 0; j < 8; j++ {
+				ata[i][j] += row[i] * row[j]
+			}
+			atb[i] += row[i] * b
+		}
+	}
+	for i := range src {
+		x, y := ts.TransformPoint(src[i].X, src[i].Y)
+		u, v := td.TransformPoint(dst[i].X, dst[i].Y)
+		add([8]float64{x, y, 1, 0, 0, 0, -x * u, -y * u}, u)
+		add([8]float64{0, 0, 0, x, y, 1, -x * v, -y * v}, v)
+	}
+	sol, ok := solveLinear(ata, atb)
+	if !ok {
+		return Homography{}, 0, fmt.Errorf("homography: points are degenerate")
+	}
+	var hn Homography
+	copy(hn[:], sol[:])
+	hn[8] = 1
+
+	h := ts.Multiply(hn).Multiply(tdInv).normalize()
+
+	var sum float64
+	for i := range src {
+		x, y := h.TransformPoint(src[i].X, src[i].Y)
+		sum += (x-dst[i].X)*(x-dst[i].X) + (y-dst[i].Y)*(y-dst[i].Y)
+	}
+	return h, math.Sqrt(sum / float64(len(src))), nil
+}
+
+// normalizePoints returns the similarity that moves the centroid of points to the
+// origin with a mean distance of √2, and its inverse
+func normalizePoints(points []Point) (t, inv Homography) {
+	var cx, cy, d float64
+	for _, p := range points {
+		cx, cy = cx+p.X, cy+p.Y
+	}
+	n := float64(len(points))
+	cx, cy = cx/n, cy/n
+	for _, p := range points {
+		d += math.Hypot(p.X-cx, p.Y-cy)
+	}
+	s := 1.0
+	if d > 0 {
+		s = math.Sqrt2 * n / d
+	}
+	return Homography{s, 0, -s * cx, 0, s, -s * cy, 0, 0, 1},
+		Homography{1 / s, 0, cx, 0, 1 / s, cy, 0, 0, 1}
+}
+
+// solveLinear solves a x = b with gaussian elimination and partial pivoting
+func solveLinear(a [8][8]float64, b [8]float64) (x [8]float64, ok bool) {
+	const n = 8
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return x, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for r := col + 1; r < n; r++ {
+			f := a[r][col] / a[col][col]
+			for c := col; c < n; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+			b[r] -= f * b[col]
+		}
+	}
+	for r := n - 1; r >= 0; r-- {
+		s := b[r]
+		for c := r + 1; c < n; c++ {
+			s -= a[r][c] * x[c]
+		}
+		x[r] = s / a[r][r]
+	}
+	return x, true
+}
+
+// Warp resamples src through the Homography into a width by height image, so that
+// each destination pixel p samples src at the inverse of h at p. Each pixel is drawn
+// by kernel with the affine approximation of h at that pixel, so that its position is
+// exact. Pixels that map outside of src, or to points of src on the far side of the
+// line at infinity of h, are left transparent.
+func (h Homography) Warp(src image.Image, kernel draw.Interpolator, width, height int) (*image.RGBA, error) {
+	inv, err := h.Invert()
+	if err != nil {
+		return nil, err
+	}
+	sr := src.Bounds()
+	cx, cy := float64(sr.Min.X+sr.Max.X)/2, float64(sr.Min.Y+sr.Max.Y)/2
+	wc := h[6]*cx + h[7]*cy + h[8]
+	if wc == 0 {
+		return nil, fmt.Errorf("homography: the center of the source image maps to infinity")
+	}
+	// inv is k times the inverse of h, so that a destination point p maps to the
+	// side of the center when w of inv at p has the sign of k / wc
+	k := h.Multiply(inv)[8]
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	px := &image.RGBA{Stride: 4}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// pixel centers, as in draw.Transformer
+			dx, dy := float64(x)+0.5, float64(y)+0.5
+			w := inv[6]*dx + inv[7]*dy + inv[8]
+			if w*k*wc <= 0 {
+				continue
+			}
+			sx, sy := (inv[0]*dx+inv[1]*dy+inv[2])/w, (inv[3]*dx+inv[4]*dy+inv[5])/w
+			if sx < float64(sr.Min.X) || sy < float64(sr.Min.Y) || sx >= float64(sr.Max.X) || sy >= float64(sr.Max.Y) {
+				continue
+			}
+			m, ok := h.jacobian(sx, sy, dx, dy)
+			if !ok {
+				continue
+			}
+			i := dst.PixOffset(x, y)
+			px.Pix, px.Rect = dst.Pix[i:i+4:i+4], image.Rect(x, y, x+1, y+1)
+			kernel.Transform(px, m.ToAffineMatrix(), src, sr, draw.Src, nil)
+		}
+	}
+	return dst, nil
+}
+
+// jacobian returns the affine approximation of h at the source point (sx,sy),
+// which h maps to the destination point (dx,dy)
+func (h Homography) jacobian(sx, sy, dx, dy float64) (Matrix, bool) {
+	w := h[6]*sx + h[7]*sy + h[8]
+	m := Matrix{
+		XX: (h[0] - dx*h[6]) / w, XY: (h[1] - dx*h[7]) / w,
+		YX: (h[3] - dy*h[6]) / w, YY: (h[4] - dy*h[7]) / w,
+	}
+	if det := m.XX*m.YY - m.XY*m.YX; det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Matrix{}, false
+	}
+	m.X0 = dx - (m.XX*sx + m.XY*sy)
+	m.Y0 = dy - (m.YX*sx + m.YY*sy)
+	return m, true
+}
@@ -0,0 +1,216 @@
+package tfimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+// testHomography maps the corners of a 128x128 image to a quadrilateral in perspective
+func testHomography(t *testing.T) Homography {
+	t.Helper()
+	h, _, err := EstimateHomography(
+		[]Point{{0, 0}, {128, 0}, {128, 128}, {0, 128}},
+		[]Point{{20, 10}, {150, 30}, {140, 150}, {10, 130}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestEstimateHomography(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	want := Homography{1.2, 0.1, 30, -0.2, 0.9, 12, 0.0004, -0.0002, 1}
+	src := randomPoints(r, 20)
+	dst := make([]Point, len(src))
+	for i, p := range src {
+		dst[i].X, dst[i].Y = want.TransformPoint(p.X, p.Y)
+	}
+	for _, n := range []int{4, 20} {
+		h, residual, err := EstimateHomography(src[:n], dst[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range h {
+			if math.Abs(h[i]-want[i]) > 1e-6*math.Max(1, math.Abs(want[i])) {
+				t.Fatalf("%d points: homography %v, want %v", n, h, want)
+			}
+		}
+		if residual > 1e-6 {
+			t.Errorf("%d points: residual %v of an exact fit", n, residual)
+		}
+	}
+
+	inv, err := want.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range src {
+		x, y := inv.TransformPoint(want.TransformPoint(p.X, p.Y))
+		if math.Hypot(x-p.X, y-p.Y) > 1e-9 {
+			t.Errorf("inverse of %v: %v,%v", p, x, y)
+		}
+	}
+	if _, _, err = EstimateHomography(src[:3], dst[:3]); err == nil {
+		t.Error("3 points: expected an error")
+	}
+	collinear := []Point{{0, 0}, {1, 1}, {2, 2}, {3, 3}}
+	if _, _, err = EstimateHomography(collinear, collinear); err == nil {
+		t.Error("collinear points: expected an error")
+	}
+}
+
+// checkerboard returns a size by size image of black and white squares of side square
+func checkerboard(size, square int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if (x/square+y/square)%2 == 0 {
+				img.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+	return img
+}
+
+func TestHomographyWarpRoundTrip(t *testing.T) {
+	const size, square, margin = 128, 16, 3
+	src := checkerboard(size, square)
+	h := testHomography(t)
+	inv, err := h.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	warped, err := h.Warp(src, draw.BiLinear, 160, 160)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := inv.Warp(warped, draw.BiLinear, size, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// away from the edges of the squares, the warps sample a constant color
+	checked := 0
+	for y := margin; y < size-margin; y++ {
+		for x := margin; x < size-margin; x++ {
+			if dx, dy := x%square, y%square; dx < margin || dx >= square-margin || dy < margin || dy >= square-margin {
+				continue
+			}
+			want := src.GrayAt(x, y).Y
+			c := back.RGBAAt(x, y)
+			if c.A != 255 || absDiff(c.R, want) > 2 || c.R != c.G || c.G != c.B {
+				t.Fatalf("pixel (%d,%d) %v, want gray %d", x, y, c, want)
+			}
+			checked++
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no pixels checked")
+	}
+
+	// the destination pixels outside of the quadrilateral stay transparent
+	for _, p := range []image.Point{{0, 0}, {159, 0}, {0, 159}, {159, 159}, {5, 60}} {
+		if c := warped.RGBAAt(p.X, p.Y); c.A != 0 {
+			t.Errorf("pixel %v outside of the warped image %v, want transparent", p, c)
+		}
+	}
+}
+
+func TestHomographyWarpStraightLines(t *testing.T) {
+	const size = 128
+	src := image.NewGray(image.Rect(0, 0, size, size))
+	draw.Draw(src, src.Rect, image.White, image.Point{}, draw.Src)
+	// a horizontal line 3 pixels thick, centered on y = 64.5
+	draw.Draw(src, image.Rect(8, 63, 120, 66), image.Black, image.Point{}, draw.Src)
+	h := testHomography(t)
+	warped, err := h.Warp(src, draw.BiLinear, 160, 160)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the line maps to the line through the images of its ends
+	x0, y0 := h.TransformPoint(8, 64.5)
+	x1, y1 := h.TransformPoint(120, 64.5)
+	lineY := func(x float64) float64 { return y0 + (x-x0)*(y1-y0)/(x1-x0) }
+	columns := 0
+	for x := int(x0) + 3; x < int(x1)-3; x++ {
+		// darkness weighted center of the column near the line
+		var sum, weight float64
+		ly := lineY(float64(x) + 0.5)
+		for y := int(ly) - 8; y <= int(ly)+8; y++ {
+			c := warped.RGBAAt(x, y)
+			if c.A == 0 {
+				t.Fatalf("pixel (%d,%d) of the line is transparent", x, y)
+			}
+			d := float64(255 - c.R)
+			sum += d * (float64(y) + 0.5)
+			weight += d
+		}
+		if weight == 0 {
+			t.Fatalf("column %d: no line", x)
+		}
+		if got := sum / weight; math.Abs(got-ly) > 0.5 {
+			t.Errorf("column %d: line at y %.2f, want %.2f", x, got, ly)
+		}
+		columns++
+	}
+	if columns < 100 {
+		t.Fatalf("%d columns checked", columns)
+	}
+}
+
+func TestHomographyWarpLineAtInfinity(t *testing.T) {
+	// w = 0.02y - 1 is zero at y = 50, inside of the source image. The rows below
+	// y = 50 are on the side of the center at y = 60, the rows above it map through
+	// infinity and land in the same destination quadrant.
+	h := Homography{1, 0, -50, 0, 1, -60, 0, 0.02, -1}
+	src := image.NewGray(image.Rect(0, 0, 100, 120))
+	draw.Draw(src, src.Rect, image.White, image.Point{}, draw.Src)
+	inv, err := h.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 200
+	warped, err := h.Warp(src, draw.NearestNeighbor, size, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	front, behind := 0, 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			sx, sy := inv.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+			inside := sx >= 1 && sy >= 1 && sx < 99 && sy < 119 // away from the edges of src
+			if !inside {
+				continue
+			}
+			a := warped.RGBAAt(x, y).A
+			if sy > 50.5 {
+				front++
+				if a != 255 {
+					t.Fatalf("pixel (%d,%d) maps to (%.1f,%.1f), on the side of the center, alpha %d", x, y, sx, sy, a)
+				}
+			} else if sy < 49.5 {
+				behind++
+				if a != 0 {
+					t.Fatalf("pixel (%d,%d) maps to (%.1f,%.1f), beyond the line at infinity, alpha %d", x, y, sx, sy, a)
+				}
+			}
+		}
+	}
+	if front == 0 || behind == 0 {
+		t.Fatalf("%d pixels on the side of the center and %d beyond the line at infinity", front, behind)
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,226 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"reflect"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// TensorToImage converts a [1,H,W,C] or [H,W,C] float32 or uint8 tensor into an image,
+// or a [1,C,H,W] or [C,H,W] tensor when d.Layout is NCHW.
+// Tensors with 3 channels produce an *image.RGBA, tensors with 1 channel an *image.Gray.
+func TensorToImage(tensor *tf.Tensor, d Denormalize) (image.Image, error) {
+	data, h, w, c, err := tensorPixels(tensor)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case d.Layout == NCHW:
+		// tensorPixels read the dimensions as C, H and W
+		data, h, w, c = chwToHWC(data, h, w, c), w, c, h
+	case c != 1 && c != 3 && (h == 1 || h == 3):
+		return nil, fmt.Errorf("tensor shape %v looks NCHW, set the Layout of Denormalize", tensor.Shape())
+	}
+
+	switch c {
+	case 1:
+		img := image.NewGray(image.Rect(0, 0, w, h))
+		for i, v := range data {
+			img.Pix[i] = d.pixel(v, 0)
+		}
+		return img, nil
+	case 3:
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for i := 0; i < w*h; i++ {
+			r, g, b := d.pixel(data[i*3], 0), d.pixel(data[i*3+1], 1), d.pixel(data[i*3+2], 2)
+			if d.BGR {
+				r, b = b, r
+			}
+			img.Pix[i*4] = r
+			img.Pix[i*4+1] = g
+			img.Pix[i*4+2] = b
+			img.Pix[i*4+3] = 0xff
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("tensor has %d channels, expected 1 or 3", c)
+}
+
+// SaveTensorPNG - Save an image tensor to PNG
+func SaveTensorPNG(path string, tensor *tf.Tensor, d Denormalize) error {
+	img, err := TensorToImage(tensor, d)
+	if err != nil {
+		return err
+	}
+
+	return SaveImage(path, img, PNGEncoder{})
+}
+
+// chwToHWC transposes c channel planes of h by w values to HWC order
+func chwToHWC(data []float32, c, h, w int) []float32 {
+	res := make([]float32, len(data))
+	for ch := 0; ch < c; ch++ {
+		for i := 0; i < h*w; i++ {
+			res[i*c+ch] = data[ch*h*w+i]
+		}
+	}
+	return res
+}
+
+// tensorPixels flattens an image tensor into float32 values in the order of its dimensions,
+// which are returned as h, w and c.
+func tensorPixels(tensor *tf.Tensor) (data []float32, h, w, c int, err error) {
+	shape := tensor.Shape()
+	if len(shape) == 4 {
+		if shape[0] != 1 {
+			return nil, 0, 0, 0, fmt.Errorf("tensor batch size is %d, expected 1", shape[0])
+		}
+		shape = shape[1:]
+	}
+	if len(shape) != 3 {
+		return nil, 0, 0, 0, fmt.Errorf("tensor shape %v is not an image", tensor.Shape())
+	}
+	h, w, c = int(shape[0]), int(shape[1]), int(shape[2])
+
+	data = make([]float32, 0, h*w*c)
+	switch v := tensor.Value().(type) {
+	case [][][][]float32:
+		for _, row := range v[0] {
+			for _, px := range row {
+				data = append(data, px...)
+			}
+		}
+	case [][][]float32:
+		for _, row := range v {
+			for _, px := range row {
+				data = append(data, px...)
+			}
+		}
+	case [][][][]uint8:
+		for _, row := range v[0] {
+			for _, px := range row {
+				for _, ch := range px {
+					data = append(data, float32(ch))
+				}
+			}
+		}
+	case [][][]uint8:
+		for _, row := range v {
+			for _, px := range row {
+				for _, ch := range px {
+					data = append(data, float32(ch))
+				}
+			}
+		}
+	default:
+		return nil, 0, 0, 0, fmt.Errorf("unsupported tensor type %s", dataTypeName(tensor.DataType()))
+	}
+	return data, h, w, c, nil
+}
+
+// ChannelStats are the statistics of one channel of a tensor
+type ChannelStats struct {
+	Min, Max, Mean float64
+}
+
+// TensorDescription describes the shape, type and values of a tensor.
+// Channels are taken along the last dimension.
+type TensorDescription struct {
+	DataType tf.DataType
+	Shape    []int64
+	Channels []ChannelStats
+}
+
+func (td TensorDescription) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s %v\n", dataTypeName(td.DataType), td.Shape))
+	for i, c := range td.Channels {
+		sb.WriteString(fmt.Sprintf(" Channel %d: \t Min: %.4f \t Max: %.4f \t Mean: %.4f \n", i, c.Min, c.Max, c.Mean))
+	}
+	return sb.String()
+}
+
+// Describe reports the shape, dtype and per channel min/max/mean of a numeric tensor
+func Describe(tensor *tf.Tensor) (TensorDescription, error) {
+	td := TensorDescription{DataType: tensor.DataType(), Shape: tensor.Shape()}
+
+	channels := 1
+	if len(td.Shape) > 1 && td.Shape[len(td.Shape)-1] > 0 {
+		channels = int(td.Shape[len(td.Shape)-1])
+	}
+	td.Channels = make([]ChannelStats, channels)
+	for i := range td.Channels {
+		td.Channels[i] = ChannelStats{Min: math.Inf(1), Max: math.Inf(-1)}
+	}
+
+	counts := make([]int, channels)
+	n := 0
+	var walk func(v reflect.Value) error
+	walk = func(v reflect.Value) error {
+		var f float64
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				if err := walk(v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		case reflect.Float32, reflect.Float64:
+			f = v.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(v.Uint())
+		default:
+			return fmt.Errorf("unsupported tensor type %s", dataTypeName(td.DataType))
+		}
+		c := &td.Channels[n%channels]
+		c.Min = math.Min(c.Min, f)
+		c.Max = math.Max(c.Max, f)
+		c.Mean += f
+		counts[n%channels]++
+		n++
+		return nil
+	}
+	if err := walk(reflect.ValueOf(tensor.Value())); err != nil {
+		return td, err
+	}
+
+	for i := range td.Channels {
+		if counts[i] == 0 {
+			td.Channels[i] = ChannelStats{}
+			continue
+		}
+		td.Channels[i].Mean /= float64(counts[i])
+	}
+	return td, nil
+}
+
+var dataTypeNames = map[tf.DataType]string{
+	tf.Float:  "float32",
+	tf.Double: "float64",
+	tf.Int32:  "int32",
+	tf.Int64:  "int64",
+	tf.Int16:  "int16",
+	tf.Int8:   "int8",
+	tf.Uint8:  "uint8",
+	tf.Uint16: "uint16",
+	tf.String: "string",
+	tf.Bool:   "bool",
+	tf.Half:   "float16",
+}
+
+func dataTypeName(dt tf.DataType) string {
+	if name, ok := dataTypeNames[dt]; ok {
+		return name
+	}
+	return fmt.Sprintf("DataType(%d)", dt)
+}
@@ -0,0 +1,78 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// testPattern returns a small image with a distinct colour at every pixel
+func testPattern(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 40), uint8(y * 40), uint8(x*y + 7), 0xff})
+		}
+	}
+	return img
+}
+
+func TestTensorToImageRoundTrip(t *testing.T) {
+	src := testPattern(5, 3)
+	data := NewImageData(src)
+
+	nhwc, err := data.tensor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nchw, err := (&goTensor{shape: []int{1, 3, data.Height, data.Width}, data: hwcToCHW(data)}).tfTensor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tensor *tf.Tensor
+		d      Denormalize
+	}{
+		{"nhwc", nhwc, Denormalize{}},
+		{"nchw", nchw, Denormalize{Layout: NCHW}},
+	}
+	for _, tt := range tests {
+		img, err := TensorToImage(tt.tensor, tt.d)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if img.Bounds() != src.Bounds() {
+			t.Fatalf("%s: bounds %v, want %v", tt.name, img.Bounds(), src.Bounds())
+		}
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				if got, want := img.At(x, y), src.At(x, y); got != want {
+					t.Errorf("%s: pixel (%d,%d) is %v, want %v", tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	if _, err := TensorToImage(nchw, Denormalize{}); err == nil {
+		t.Error("NCHW tensor without Layout: expected an error")
+	}
+}
+
+// hwcToCHW returns the values of the ImageData as channel planes
+func hwcToCHW(data *ImageData) []float32 {
+	n := data.Width * data.Height
+	res := make([]float32, len(data.Pix))
+	for i := 0; i < n; i++ {
+		for c := 0; c < 3; c++ {
+			res[c*n+i] = data.Pix[i*3+c]
+		}
+	}
+	return res
+}
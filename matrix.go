@@ -6,6 +6,7 @@ package tfimage
 // LICENSE: MIT
 
 import (
+	"errors"
 	"math"
 
 	"golang.org/x/image/math/f64"
@@ -137,6 +138,68 @@ func (m Matrix) Shear(x, y float64) Matrix {
 	return Shear(x, y).Multiply(m)
 }
 
+// ErrSingularMatrix is returned when inverting a Matrix that has no inverse
+var ErrSingularMatrix = errors.New("matrix is singular")
+
+// Determinant returns the determinant of the linear part of the Matrix
+func (m Matrix) Determinant() float64 {
+	return m.XX*m.YY - m.XY*m.YX
+}
+
+// Invert returns the inverse of the Matrix, so that m.Multiply(inv) is the identity.
+// It returns ErrSingularMatrix when the Matrix has no inverse.
+func (m Matrix) Invert() (Matrix, error) {
+	det := m.Determinant()
+	norm := math.Max(math.Max(math.Abs(m.XX), math.Abs(m.XY)), math.Max(math.Abs(m.YX), math.Abs(m.YY)))
+	if math.Abs(det) <= 1e-12*norm*norm || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Matrix{}, ErrSingularMatrix
+	}
+	inv := Matrix{
+		m.YY / det, -m.YX / det,
+		-m.XY / det, m.XX / det,
+		0, 0,
+	}
+	inv.X0 = -(inv.XX*m.X0 + inv.XY*m.Y0)
+	inv.Y0 = -(inv.YX*m.X0 + inv.YY*m.Y0)
+	return inv, nil
+}
+
+// Decomposition - The components of a Matrix, applied in the order scale, shear,
+// rotation and translation. Rotation is in radians, in the direction of Rotate,
+// and Shear is the x shear of Shear(shear, 0).
+type Decomposition struct {
+	TranslateX, TranslateY float64
+	Rotation               float64
+	ScaleX, ScaleY         float64
+	Shear                  float64
+}
+
+// Decompose returns the translation, rotation, scale and shear of the Matrix.
+// A reflection is returned as a negative ScaleY.
+func (m Matrix) Decompose() Decomposition {
+	d := Decomposition{TranslateX: m.X0, TranslateY: m.Y0}
+	d.ScaleX = math.Hypot(m.XX, m.YX)
+	if d.ScaleX == 0 {
+		d.ScaleY = math.Hypot(m.XY, m.YY)
+		return d
+	}
+	d.Rotation = math.Atan2(m.YX, m.XX)
+	c, s := m.XX/d.ScaleX, m.YX/d.ScaleX
+	d.ScaleY = m.Determinant() / d.ScaleX
+	if d.ScaleY != 0 {
+		d.Shear = (c*m.XY + s*m.YY) / d.ScaleY
+	}
+	return d
+}
+
+// Matrix composes the Matrix of the Decomposition
+func (d Decomposition) Matrix() Matrix {
+	return Scale(d.ScaleX, d.ScaleY).
+		Multiply(Shear(d.Shear, 0)).
+		Multiply(Rotate(d.Rotation)).
+		Multiply(Translate(d.TranslateX, d.TranslateY))
+}
+
 func (m Matrix) ToAffineMatrix() f64.Aff3 {
 	return f64.Aff3{m.XX, m.XY, m.X0, m.YX, m.YY, m.Y0}
 }
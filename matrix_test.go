@@ -0,0 +1,86 @@
+package tfimage
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const matrixTolerance = 1e-9
+
+// randomMatrix returns a matrix with a random rotation, scale, shear and
+// translation, and a reflection for about a quarter of them
+func randomMatrix(r *rand.Rand) Matrix {
+	d := Decomposition{
+		TranslateX: r.Float64()*2000 - 1000,
+		TranslateY: r.Float64()*2000 - 1000,
+		Rotation:   r.Float64()*2*math.Pi - math.Pi,
+		ScaleX:     0.05 + r.Float64()*10,
+		ScaleY:     0.05 + r.Float64()*10,
+		Shear:      r.Float64()*4 - 2,
+	}
+	if r.Intn(4) == 0 {
+		d.ScaleY = -d.ScaleY
+	}
+	return d.Matrix()
+}
+
+func matricesEqual(a, b Matrix, tol float64) bool {
+	for _, d := range []float64{a.XX - b.XX, a.YX - b.YX, a.XY - b.XY, a.YY - b.YY} {
+		if math.Abs(d) > tol {
+			return false
+		}
+	}
+	// the translation is compared relative to its magnitude
+	scale := math.Max(1, math.Max(math.Abs(a.X0), math.Abs(a.Y0)))
+	return math.Abs(a.X0-b.X0) <= tol*scale && math.Abs(a.Y0-b.Y0) <= tol*scale
+}
+
+func TestMatrixInvert(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		m := randomMatrix(r)
+		inv, err := m.Invert()
+		if err != nil {
+			t.Fatalf("%v: %v", m, err)
+		}
+		if p := m.Multiply(inv); !matricesEqual(p, NewMatrix(), matrixTolerance) {
+			t.Fatalf("m.Multiply(inv) = %v, want the identity, m = %v", p, m)
+		}
+		if p := inv.Multiply(m); !matricesEqual(p, NewMatrix(), matrixTolerance) {
+			t.Fatalf("inv.Multiply(m) = %v, want the identity, m = %v", p, m)
+		}
+	}
+}
+
+func TestMatrixInvertSingular(t *testing.T) {
+	for _, m := range []Matrix{
+		{},
+		Scale(0, 1),
+		Scale(2, 0).Multiply(Translate(5, 5)),
+		{XX: 1, YX: 2, XY: 2, YY: 4, X0: 3, Y0: 1}, // parallel columns
+		{XX: math.NaN(), YY: 1},
+		{XX: math.Inf(1), YY: 1},
+	} {
+		if _, err := m.Invert(); err != ErrSingularMatrix {
+			t.Errorf("%v.Invert() error = %v, want ErrSingularMatrix", m, err)
+		}
+	}
+}
+
+func TestMatrixDecompose(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 10000; i++ {
+		m := randomMatrix(r)
+		d := m.Decompose()
+		if got := d.Matrix(); !matricesEqual(got, m, matrixTolerance) {
+			t.Fatalf("Decompose(%v).Matrix() = %v", m, got)
+		}
+		if d.ScaleX <= 0 {
+			t.Fatalf("Decompose(%v).ScaleX = %v, want > 0", m, d.ScaleX)
+		}
+		if d.ScaleY < 0 != (m.Determinant() < 0) {
+			t.Fatalf("Decompose(%v).ScaleY = %v, the sign does not match the determinant", m, d.ScaleY)
+		}
+	}
+}
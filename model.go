@@ -0,0 +1,178 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Model - A frozen tensorflow graph with a named input and output Signature.
+//
+// Model handles loading, preprocessing and the lifecycle of the graph's session,
+// so that custom graphs can be wrapped without using a tf.Session directly:
+//
+//	m, err := tfimage.NewModel("classifier.pb", tfimage.Signature{
+//		Inputs:  map[string]string{tfimage.ImageInput: "input"},
+//		Outputs: map[string]string{"scores": "softmax"},
+//	}, tfimage.ModelOptions{})
+//	out, err := m.Run(map[string]*tf.Tensor{tfimage.ImageInput: img}, "scores")
+type Model struct {
+	graph      *tf.Graph
+	session    *tf.Session
+	preprocess *preprocessor
+
+	signature Signature
+	options   ModelOptions
+	inputs    map[string]tf.Output
+	outputs   map[string]tf.Output
+}
+
+// NewModel - Creates a new Model from a frozen GraphDef model file
+func NewModel(modelFile string, sig Signature, options ModelOptions) (*Model, error) {
+	def, err := ioutil.ReadFile(modelFile)
+	if err != nil {
+		return nil, err
+	}
+	return newModel(def, sig, options)
+}
+
+// NewModelFromBytes - Creates a new Model from a serialized frozen GraphDef
+func NewModelFromBytes(def []byte, sig Signature, options ModelOptions) (*Model, error) {
+	return newModel(def, sig, options)
+}
+
+// NewModelFromReader - Creates a new Model from a reader of a frozen GraphDef
+func NewModelFromReader(r io.Reader, sig Signature, options ModelOptions) (*Model, error) {
+	def, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return newModel(def, sig, options)
+}
+
+// NewModelFromFS - Creates a new Model from a frozen GraphDef model file in fsys,
+// such as an embed.FS
+func NewModelFromFS(fsys fs.FS, name string, sig Signature, options ModelOptions) (*Model, error) {
+	def, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return newModel(def, sig, options)
+}
+
+// newModel creates a new Model from a serialized GraphDef
+func newModel(def []byte, sig Signature, options ModelOptions) (*Model, error) {
+	m := &Model{signature: sig, options: options}
+
+	m.graph = tf.NewGraph()
+	if err := m.graph.Import(def, ""); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if m.inputs, m.outputs, err = sig.resolve(m.graph); err != nil {
+		return nil, err
+	}
+
+	m.session, err = tf.NewSession(m.graph, options.Session.tfOptions())
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Signature returns the Signature of the Model
+func (m *Model) Signature() Signature {
+	return m.signature
+}
+
+// SetPreprocess attaches a Preprocess that is applied to the ImageInput tensor.
+func (m *Model) SetPreprocess(p Preprocess) error {
+	pp, err := newPreprocessor(p, m.options.Session)
+	if err != nil {
+		return err
+	}
+	if m.preprocess != nil {
+		m.preprocess.Close()
+	}
+	m.preprocess = pp
+	return nil
+}
+
+// Run feeds the named input tensors to the Model and returns the named outputs.
+func (m *Model) Run(inputs map[string]*tf.Tensor, outputs ...string) (map[string]*tf.Tensor, error) {
+	res, _, err := m.run(inputs, outputs)
+	return res, err
+}
+
+// run feeds the named input tensors to the Model. It also returns the Matrix that maps
+// points of the preprocessed image back to points of the ImageInput tensor.
+func (m *Model) run(inputs map[string]*tf.Tensor, outputs []string) (map[string]*tf.Tensor, Matrix, error) {
+	if m.session == nil {
+		return nil, Matrix{}, fmt.Errorf("model is closed")
+	}
+
+	inverse := NewMatrix()
+	feeds := make(map[tf.Output]*tf.Tensor, len(inputs))
+	for name, tensor := range inputs {
+		input, ok := m.inputs[name]
+		if !ok {
+			return nil, Matrix{}, fmt.Errorf("unknown model input %q", name)
+		}
+		if name == ImageInput && m.preprocess != nil {
+			var err error
+			if tensor, inverse, err = m.preprocess.Apply(tensor); err != nil {
+				return nil, Matrix{}, err
+			}
+		}
+		feeds[input] = tensor
+	}
+
+	fetches := make([]tf.Output, len(outputs))
+	for i, name := range outputs {
+		output, ok := m.outputs[name]
+		if !ok {
+			return nil, Matrix{}, fmt.Errorf("unknown model output %q", name)
+		}
+		fetches[i] = output
+	}
+
+	values, err := m.session.Run(feeds, fetches, nil)
+	if err != nil {
+		return nil, Matrix{}, err
+	}
+
+	res := make(map[string]*tf.Tensor, len(outputs))
+	for i, name := range outputs {
+		res[name] = values[i]
+	}
+	return res, inverse, nil
+}
+
+// Close closes the Model's Session
+func (m *Model) Close() {
+	if m.session != nil {
+		m.session.Close()
+		m.graph = nil
+		m.session = nil
+	}
+	if m.preprocess != nil {
+		m.preprocess.Close()
+		m.preprocess = nil
+	}
+}
+
+// tfOptions returns the tf.SessionOptions, or nil for tensorflow's defaults
+func (o SessionOptions) tfOptions() *tf.SessionOptions {
+	config := o.Config()
+	if len(config) == 0 {
+		return nil
+	}
+	return &tf.SessionOptions{Config: config}
+}
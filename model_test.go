@@ -0,0 +1,131 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// doubleGraph returns a GraphDef that doubles a [1,H,W,3] float32 image,
+// and the names of its input and output operations
+func doubleGraph(t *testing.T) (def []byte, input, output string) {
+	t.Helper()
+	s := op.NewScope()
+	in := op.Placeholder(s.SubScope("input"), tf.Float, op.PlaceholderShape(tf.MakeShape(1, -1, -1, 3)))
+	out := op.Mul(s.SubScope("double"), in, op.Const(s.SubScope("two"), float32(2)))
+	graph, err := s.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = graph.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), in.Op.Name(), out.Op.Name()
+}
+
+func TestModelRun(t *testing.T) {
+	def, input, output := doubleGraph(t)
+	m, err := NewModelFromBytes(def, Signature{
+		Inputs:  map[string]string{ImageInput: input},
+		Outputs: map[string]string{"double": output},
+	}, ModelOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	img, err := tf.NewTensor([][][][]float32{{{{1, 2, 3}, {4, 5, 6}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Run(map[string]*tf.Tensor{ImageInput: img}, "double")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][][][]float32{{{{2, 4, 6}, {8, 10, 12}}}}
+	if got := out["double"].Value(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Run = %v, want %v", got, want)
+	}
+
+	if _, err = m.Run(map[string]*tf.Tensor{ImageInput: img}, "missing"); err == nil {
+		t.Error("unknown output: expected an error")
+	}
+	if _, err = m.Run(map[string]*tf.Tensor{"missing": img}, "double"); err == nil {
+		t.Error("unknown input: expected an error")
+	}
+
+	// the Preprocess is applied to the image input before the graph
+	if err = m.SetPreprocess(Preprocess{Scale: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if out, err = m.Run(map[string]*tf.Tensor{ImageInput: img}, "double"); err != nil {
+		t.Fatal(err)
+	}
+	want = [][][][]float32{{{{1, 2, 3}, {4, 5, 6}}}}
+	if got := out["double"].Value(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Run with Preprocess = %v, want %v", got, want)
+	}
+
+	m.Close()
+	if _, err = m.Run(map[string]*tf.Tensor{ImageInput: img}, "double"); err == nil {
+		t.Error("closed model: expected an error")
+	}
+}
+
+func TestSignatureValidation(t *testing.T) {
+	def, input, output := doubleGraph(t)
+	tests := []struct {
+		name    string
+		sig     Signature
+		problem string
+	}{
+		{"missing operation", Signature{
+			Inputs:  map[string]string{ImageInput: input},
+			Outputs: map[string]string{"scores": "dense_1/Softmax"},
+		}, `"scores": operation "dense_1/Softmax" not found`},
+		{"missing output index", Signature{
+			Outputs: map[string]string{"double": output + ":1"},
+		}, "has 1 outputs"},
+		{"dtype", Signature{
+			Inputs: map[string]string{ImageInput: input},
+			Specs:  map[string]TensorSpec{ImageInput: {DType: tf.Int32, Rank: 4}},
+		}, "dtype float32, expected int32"},
+		{"rank", Signature{
+			Outputs: map[string]string{"double": output},
+			Specs:   map[string]TensorSpec{"double": {DType: tf.Float, Rank: 2}},
+		}, "rank 4, expected 2"},
+	}
+	for _, tt := range tests {
+		_, err := NewModelFromBytes(def, tt.sig, ModelOptions{})
+		var sigErr *SignatureError
+		if !errors.As(err, &sigErr) {
+			t.Errorf("%s: error %v, want a *SignatureError", tt.name, err)
+			continue
+		}
+		if len(sigErr.Problems) != 1 || !strings.Contains(sigErr.Problems[0], tt.problem) {
+			t.Errorf("%s: problems %q, want %q", tt.name, sigErr.Problems, tt.problem)
+		}
+		if len(sigErr.Inputs) != 1 || !strings.HasPrefix(sigErr.Inputs[0], input) {
+			t.Errorf("%s: graph inputs %q, want %s", tt.name, sigErr.Inputs, input)
+		}
+	}
+
+	// a face detection graph passed as an aesthetics model is an error, not a panic
+	_, err := NewAestheticsEvaluatorFromBytes(readModel(t, "mtcnn_1.14.pb"), AestheticsOptions{})
+	var sigErr *SignatureError
+	if !errors.As(err, &sigErr) {
+		t.Fatalf("MTCNN graph as NIMA: error %v, want a *SignatureError", err)
+	}
+	if !strings.Contains(sigErr.Error(), "input_1") {
+		t.Errorf("error %q does not name the missing input", sigErr)
+	}
+}
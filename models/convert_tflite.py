@@ -0,0 +1,153 @@
+"""Converts the frozen MTCNN and NIMA graphs to TensorFlow Lite models for the
+TFLiteBackend (build with -tags tflite).
+
+    python convert_tflite.py mtcnn mtcnn_1.14.pb mtcnn_1.14.tflite
+    python convert_tflite.py nima nima_1.14.pb nima_1.14.tflite
+
+The MTCNN model has "pnet", "rnet" and "onet" signatures, each with an "input"
+of shape [N,H,W,3] and "prob" and "reg" outputs, plus "landmarks" for the O-Net.
+The networks are rebuilt from the weights of the frozen graph with the layers of
+mtcnn.go, and the cascade between them runs in Go.
+
+The NIMA model has the single input "input_1" of shape [1,224,224,3] and the
+score distribution as its output.
+
+Requires TensorFlow 2.7 or later.
+"""
+import argparse
+import tempfile
+
+import numpy as np
+import tensorflow as tf
+
+# Layers of the MTCNN networks, as in mtcnn.go. Convolutions are VALID with a
+# stride of 1, pooling layers are (window, stride, padding).
+MTCNN_LAYERS = {
+    'pnet': [
+        'conv1', ('prelu', 'PReLU1'), (2, 2, 'VALID'),
+        'conv2', ('prelu', 'PReLU2'),
+        'conv3', ('prelu', 'PReLU3'),
+    ],
+    'rnet': [
+        'conv1', ('prelu', 'prelu1'), (3, 2, 'SAME'),
+        'conv2', ('prelu', 'prelu2'), (3, 2, 'VALID'),
+        'conv3', ('prelu', 'prelu3'),
+        'conv4', ('prelu', 'prelu4'),
+    ],
+    'onet': [
+        'conv1', ('prelu', 'prelu1'), (3, 2, 'SAME'),
+        'conv2', ('prelu', 'prelu2'), (3, 2, 'VALID'),
+        'conv3', ('prelu', 'prelu3'), (2, 2, 'VALID'),
+        'conv4', ('prelu', 'prelu4'),
+        'conv5', ('prelu', 'prelu5'),
+    ],
+}
+MTCNN_HEADS = {
+    'pnet': ['conv4-1', 'conv4-2'],
+    'rnet': ['conv5-1', 'conv5-2'],
+    'onet': ['conv6-1', 'conv6-2', 'conv6-3'],
+}
+MTCNN_OUTPUTS = ['prob', 'reg', 'landmarks']
+
+NIMA_INPUT = 'input_1'
+NIMA_OUTPUT = 'dense_1/Softmax'
+
+
+def load_constants(path):
+    graph_def = tf.compat.v1.GraphDef()
+    with open(path, 'rb') as f:
+        graph_def.ParseFromString(f.read())
+    return {n.name: tf.make_ndarray(n.attr['value'].tensor)
+            for n in graph_def.node if n.op == 'Const'}
+
+
+class MTCNN(tf.Module):
+    def __init__(self, constants):
+        super().__init__()
+        self.weights = {}
+        for net, layers in MTCNN_LAYERS.items():
+            names = [l for l in layers if isinstance(l, str)] + MTCNN_HEADS[net]
+            for name in names:
+                for part in ('weights', 'biases'):
+                    key = '%s/%s/%s' % (net, name, part)
+                    self.weights[key] = tf.constant(constants[key])
+            for l in layers:
+                if isinstance(l, tuple) and l[0] == 'prelu':
+                    key = '%s/%s/weights' % (net, l[1])
+                    self.weights[key] = tf.constant(constants[key])
+
+    def conv(self, x, name):
+        x = tf.nn.conv2d(x, self.weights[name + '/weights'], 1, 'VALID')
+        return tf.nn.bias_add(x, self.weights[name + '/biases'])
+
+    def net(self, net, x):
+        for l in MTCNN_LAYERS[net]:
+            if isinstance(l, str):
+                x = self.conv(x, '%s/%s' % (net, l))
+            elif l[0] == 'prelu':
+                alpha = self.weights['%s/%s/weights' % (net, l[1])]
+                x = tf.maximum(x, 0.0) + alpha * tf.minimum(x, 0.0)
+            else:
+                x = tf.nn.max_pool2d(x, l[0], l[1], l[2])
+        outputs = [self.conv(x, '%s/%s' % (net, head)) for head in MTCNN_HEADS[net]]
+        outputs[0] = tf.nn.softmax(outputs[0])
+        return dict(zip(MTCNN_OUTPUTS, outputs))
+
+    spec = tf.TensorSpec([None, None, None, 3], tf.float32, name='input')
+
+    @tf.function(input_signature=[spec])
+    def pnet(self, input):
+        return self.net('pnet', input)
+
+    @tf.function(input_signature=[spec])
+    def rnet(self, input):
+        return self.net('rnet', input)
+
+    @tf.function(input_signature=[spec])
+    def onet(self, input):
+        return self.net('onet', input)
+
+
+def check_mtcnn(module, model):
+    """Compares the signatures of the TFLite model to the rebuilt networks"""
+    interp = tf.lite.Interpreter(model_content=model)
+    sizes = {'pnet': (1, 64, 48, 3), 'rnet': (4, 24, 24, 3), 'onet': (4, 48, 48, 3)}
+    for net, shape in sizes.items():
+        x = np.random.uniform(-1, 1, shape).astype(np.float32)
+        want = getattr(module, net)(tf.constant(x))
+        got = interp.get_signature_runner(net)(input=x)
+        for k, v in want.items():
+            diff = np.abs(got[k] - v.numpy()).max()
+            print('%s/%s: max difference %.2e' % (net, k, diff))
+            assert diff < 1e-4, '%s/%s differs by %f' % (net, k, diff)
+
+
+def convert_mtcnn(src, dst):
+    module = MTCNN(load_constants(src))
+    with tempfile.TemporaryDirectory() as saved:
+        signatures = {net: getattr(module, net).get_concrete_function() for net in MTCNN_LAYERS}
+        tf.saved_model.save(module, saved, signatures=signatures)
+        converter = tf.lite.TFLiteConverter.from_saved_model(saved, signature_keys=list(MTCNN_LAYERS))
+        model = converter.convert()
+    check_mtcnn(module, model)
+    with open(dst, 'wb') as f:
+        f.write(model)
+
+
+def convert_nima(src, dst):
+    converter = tf.compat.v1.lite.TFLiteConverter.from_frozen_graph(
+        src, [NIMA_INPUT], [NIMA_OUTPUT], input_shapes={NIMA_INPUT: [1, 224, 224, 3]})
+    with open(dst, 'wb') as f:
+        f.write(converter.convert())
+
+
+if __name__ == '__main__':
+    parser = argparse.ArgumentParser(description='Convert tfimage models to TensorFlow Lite')
+    parser.add_argument('kind', choices=['mtcnn', 'nima'])
+    parser.add_argument('src', help='frozen graph (.pb)')
+    parser.add_argument('dst', help='TensorFlow Lite model (.tflite)')
+    args = parser.parse_args()
+    if args.kind == 'mtcnn':
+        convert_mtcnn(args.src, args.dst)
+    else:
+        convert_nima(args.src, args.dst)
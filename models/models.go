@@ -0,0 +1,20 @@
+// Package models embeds the MTCNN model bundled with tfimage, so that
+// a FaceDetector can be created without any model files on disk.
+package models
+
+import (
+	_ "embed" // embed the bundled model
+
+	"github.com/evanoberholster/tfimage"
+)
+
+// MTCNN is the bundled MTCNN face detection model (mtcnn_1.14.pb)
+//
+//go:embed mtcnn_1.14.pb
+var MTCNN []byte
+
+// NewDefaultFaceDetector - Create a New FaceDetector from the bundled MTCNN model
+// with the default FaceDetectorOptions.
+func NewDefaultFaceDetector() (*tfimage.FaceDetector, error) {
+	return tfimage.NewFaceDetectorFromBytes(MTCNN, tfimage.FaceDetectorOptions{})
+}
@@ -0,0 +1,439 @@
+package tfimage
+
+import (
+	"fmt"
+	"time"
+)
+
+// MTCNN cascade constants of the bundled models
+const (
+	mtcnnCellSize = 12
+	mtcnnStride   = 2
+	mtcnnMaxBoxes = 1000
+	mtcnnPixScale = 128 // the models are fed pixel / 128
+)
+
+// mtcnnNets runs the networks of the MTCNN cascade on normalized images
+type mtcnnNets interface {
+	// pnet runs the proposal network on an image [1,H,W,3], returning the face
+	// probabilities [1,h,w,2] and box regressions [1,h,w,4] of the cells
+	pnet(img *goTensor) (prob, reg *goTensor, err error)
+	// rnet runs the refinement network on crops [N,24,24,3], returning [N,2] and [N,4]
+	rnet(crops *goTensor) (prob, reg *goTensor, err error)
+	// onet runs the output network on crops [N,48,48,3], returning [N,2], [N,4] and landmarks [N,10]
+	onet(crops *goTensor) (prob, reg, landmarks *goTensor, err error)
+	close()
+}
+
+// FaceCandidate - A face box [y1, x1, y2, x2] and its score at a stage of the MTCNN cascade
+type FaceCandidate struct {
+	Box   [4]float32
+	Score float32
+}
+
+// MTCNNStage - The candidates of a stage of the MTCNN cascade, for debugging and tuning
+type MTCNNStage struct {
+	Name string // pnet, rnet or onet
+
+	// Candidates passed the score threshold of the stage. The P-Net candidates
+	// are merged from the non max suppression of each scale.
+	Candidates []FaceCandidate
+	// Output are the candidates kept by non max suppression after box regression,
+	// and squaring for the P-Net and R-Net. They are the input of the next stage.
+	Output []FaceCandidate
+
+	Duration time.Duration
+}
+
+// mtcnnCandidates are the face candidates of a stage of the MTCNN cascade
+type mtcnnCandidates struct {
+	boxes     [][4]float32 // [y1, x1, y2, x2]
+	scores    []float32
+	regs      [][4]float32
+	landmarks [][10]float32
+}
+
+func (c *mtcnnCandidates) add(box [4]float32, score float32, reg [4]float32) {
+	c.boxes = append(c.boxes, box)
+	c.scores = append(c.scores, score)
+	c.regs = append(c.regs, reg)
+}
+
+// gather returns the candidates at the indices idx
+func (c *mtcnnCandidates) gather(idx []int) *mtcnnCandidates {
+	res := &mtcnnCandidates{}
+	for _, i := range idx {
+		res.add(c.boxes[i], c.scores[i], c.regs[i])
+		if c.landmarks != nil {
+			res.landmarks = append(res.landmarks, c.landmarks[i])
+		}
+	}
+	return res
+}
+
+// nms returns the candidates selected by non max suppression
+func (c *mtcnnCandidates) nms(iou float32) *mtcnnCandidates {
+	return c.gather(nonMaxSuppression(c.boxes, c.scores, iou, mtcnnMaxBoxes))
+}
+
+// regress applies the box regressions to the boxes
+func (c *mtcnnCandidates) regress() {
+	for i, b := range c.boxes {
+		h, w := b[2]-b[0], b[3]-b[1]
+		r := c.regs[i]
+		c.boxes[i] = [4]float32{b[0] + h*r[0], b[1] + w*r[1], b[2] + h*r[2], b[3] + w*r[3]}
+	}
+}
+
+// square turns the boxes into squares around their centers
+func (c *mtcnnCandidates) square() {
+	for i, b := range c.boxes {
+		h, w := b[2]-b[0]+1, b[3]-b[1]+1
+		l := maxFloat(h, w)
+		dy, dx := (h-l)*0.5, (w-l)*0.5
+		c.boxes[i] = [4]float32{b[0] + dy, b[1] + dx, b[2] - dy, b[3] - dx}
+	}
+}
+
+// faceCandidates returns the boxes and scores of the candidates
+func (c *mtcnnCandidates) faceCandidates() []FaceCandidate {
+	res := make([]FaceCandidate, len(c.boxes))
+	for i := range res {
+		res[i] = FaceCandidate{Box: c.boxes[i], Score: c.scores[i]}
+	}
+	return res
+}
+
+// normalized returns the boxes scaled to [0,1] by the size of the image
+func (c *mtcnnCandidates) normalized(h, w int) [][4]float32 {
+	res := make([][4]float32, len(c.boxes))
+	fh, fw := float32(h), float32(w)
+	for i, b := range c.boxes {
+		res[i] = [4]float32{b[0] / fh, b[1] / fw, b[2] / fh, b[3] / fw}
+	}
+	return res
+}
+
+// runMTCNN runs the MTCNN cascade on img with nets and returns the faces and
+// the candidates of each stage. The cascade follows the graph of the bundled
+// models, so that the results of the backends and the fused graph match.
+func runMTCNN(nets mtcnnNets, img *ImageData, p MTCNNParams) ([]Face, []MTCNNStage, error) {
+	if err := img.check(); err != nil {
+		return nil, nil, err
+	}
+	if p.MinSize <= 0 || p.Factor <= 0 || p.Factor >= 1 {
+		return nil, nil, fmt.Errorf("invalid MTCNN minimum size %v or factor %v", p.MinSize, p.Factor)
+	}
+
+	x := newGoTensor(1, img.Height, img.Width, 3)
+	for i, v := range img.Pix {
+		x.data[i] = v / mtcnnPixScale
+	}
+
+	stages := []MTCNNStage{{Name: "pnet"}, {Name: "rnet"}, {Name: "onet"}}
+	start := time.Now()
+	c, err := mtcnnProposals(nets, x, p, &stages[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	stages[0].Duration, start = time.Since(start), time.Now()
+	if c, err = mtcnnRefine(nets, x, c, p.Thresholds[1], &stages[1]); err != nil {
+		return nil, nil, err
+	}
+	stages[1].Duration, start = time.Since(start), time.Now()
+	if c, err = mtcnnOutput(nets, x, c, p.Thresholds[2], &stages[2]); err != nil {
+		return nil, nil, err
+	}
+	stages[2].Duration = time.Since(start)
+
+	faces := make([]Face, len(c.boxes))
+	for i := range faces {
+		faces[i] = newFace(c.scores[i], c.boxes[i][:], c.landmarks[i][:])
+	}
+	return faces, stages, nil
+}
+
+// mtcnnProposals runs the P-Net over an image pyramid and returns the squared candidate boxes
+func mtcnnProposals(nets mtcnnNets, x *goTensor, p MTCNNParams, stage *MTCNNStage) (*mtcnnCandidates, error) {
+	h, w := float32(x.dim(1)), float32(x.dim(2))
+	min := minFloat(h, w)
+
+	all := &mtcnnCandidates{}
+	for scale := mtcnnCellSize / p.MinSize; min*scale > mtcnnCellSize; scale *= p.Factor {
+		resized, err := resizeBilinear(x, int(h*scale), int(w*scale))
+		if err != nil {
+			return nil, err
+		}
+		prob, reg, err := nets.pnet(resized)
+		if err != nil {
+			return nil, fmt.Errorf("pnet: %v", err)
+		}
+
+		c := &mtcnnCandidates{}
+		ph, pw := prob.dim(1), prob.dim(2)
+		for y := 0; y < ph; y++ {
+			for x := 0; x < pw; x++ {
+				i := y*pw + x
+				if score := prob.data[i*2+1]; score > p.Thresholds[0] {
+					box := [4]float32{
+						float32(mtcnnStride*y+1) / scale,
+						float32(mtcnnStride*x+1) / scale,
+						float32(mtcnnStride*y+mtcnnCellSize) / scale,
+						float32(mtcnnStride*x+mtcnnCellSize) / scale,
+					}
+					var r [4]float32
+					copy(r[:], reg.data[i*4:])
+					c.add(box, score, r)
+				}
+			}
+		}
+		c = c.nms(0.5)
+		all.boxes = append(all.boxes, c.boxes...)
+		all.scores = append(all.scores, c.scores...)
+		all.regs = append(all.regs, c.regs...)
+	}
+	stage.Candidates = all.faceCandidates()
+
+	all = all.nms(0.7)
+	all.regress()
+	all.square()
+	stage.Output = all.faceCandidates()
+	return all, nil
+}
+
+// mtcnnRefine runs the R-Net on the candidates and returns the squared refined boxes
+func mtcnnRefine(nets mtcnnNets, x *goTensor, c *mtcnnCandidates, threshold float32, stage *MTCNNStage) (*mtcnnCandidates, error) {
+	if len(c.boxes) == 0 {
+		return c, nil
+	}
+	crops, err := cropAndResize(x, c.normalized(x.dim(1), x.dim(2)), 24)
+	if err != nil {
+		return nil, err
+	}
+	prob, reg, err := nets.rnet(crops)
+	if err != nil {
+		return nil, fmt.Errorf("rnet: %v", err)
+	}
+
+	res := &mtcnnCandidates{}
+	for i, box := range c.boxes {
+		if score := prob.data[i*2+1]; score > threshold {
+			var r [4]float32
+			copy(r[:], reg.data[i*4:])
+			res.add(box, score, r)
+		}
+	}
+	stage.Candidates = res.faceCandidates()
+
+	res = res.nms(0.7)
+	res.regress()
+	res.square()
+	stage.Output = res.faceCandidates()
+	return res, nil
+}
+
+// mtcnnOutput runs the O-Net on the candidates and returns the final boxes and landmarks
+func mtcnnOutput(nets mtcnnNets, x *goTensor, c *mtcnnCandidates, threshold float32, stage *MTCNNStage) (*mtcnnCandidates, error) {
+	if len(c.boxes) == 0 {
+		return c, nil
+	}
+	crops, err := cropAndResize(x, c.normalized(x.dim(1), x.dim(2)), 48)
+	if err != nil {
+		return nil, err
+	}
+	prob, reg, lm, err := nets.onet(crops)
+	if err != nil {
+		return nil, fmt.Errorf("onet: %v", err)
+	}
+
+	res := &mtcnnCandidates{landmarks: [][10]float32{}}
+	for i, b := range c.boxes {
+		score := prob.data[i*2+1]
+		if score <= threshold {
+			continue
+		}
+		var r [4]float32
+		copy(r[:], reg.data[i*4:])
+		res.add(b, score, r)
+
+		// Landmarks are relative to the box before regression
+		h, w := b[2]-b[0], b[3]-b[1]
+		var l [10]float32
+		for j := 0; j < 5; j++ {
+			l[j] = b[0] + h*lm.data[i*10+j]
+			l[j+5] = b[1] + w*lm.data[i*10+j+5]
+		}
+		res.landmarks = append(res.landmarks, l)
+	}
+	stage.Candidates = res.faceCandidates()
+
+	res.regress()
+	res = res.nms(0.6)
+	stage.Output = res.faceCandidates()
+	return res, nil
+}
+
+// cascadeModel is a CascadeFaceModel that drives the MTCNN networks from Go
+type cascadeModel struct {
+	nets mtcnnNets
+}
+
+func (m *cascadeModel) DetectFaces(img *ImageData, params MTCNNParams) ([]Face, error) {
+	faces, _, err := m.DetectStages(img, params)
+	return faces, err
+}
+
+func (m *cascadeModel) DetectStages(img *ImageData, params MTCNNParams) ([]Face, []MTCNNStage, error) {
+	if m.nets == nil {
+		return nil, nil, errModelClosed
+	}
+	return runMTCNN(m.nets, img, params)
+}
+
+func (m *cascadeModel) Close() {
+	if m.nets != nil {
+		m.nets.close()
+		m.nets = nil
+	}
+}
+
+// mtcnnLayer is a layer of the P-Net, R-Net or O-Net
+type mtcnnLayer struct {
+	name   string // weights of a conv or prelu layer, empty for max pooling
+	prelu  bool
+	k      int // pooling window
+	stride int // pooling stride
+	same   bool
+}
+
+// Layers of the MTCNN networks, the convolutions are VALID with a stride of 1
+var (
+	pnetLayers = []mtcnnLayer{
+		{name: "conv1"}, {name: "PReLU1", prelu: true}, {k: 2, stride: 2},
+		{name: "conv2"}, {name: "PReLU2", prelu: true},
+		{name: "conv3"}, {name: "PReLU3", prelu: true},
+	}
+	rnetLayers = []mtcnnLayer{
+		{name: "conv1"}, {name: "prelu1", prelu: true}, {k: 3, stride: 2, same: true},
+		{name: "conv2"}, {name: "prelu2", prelu: true}, {k: 3, stride: 2},
+		{name: "conv3"}, {name: "prelu3", prelu: true},
+		{name: "conv4"}, {name: "prelu4", prelu: true},
+	}
+	onetLayers = []mtcnnLayer{
+		{name: "conv1"}, {name: "prelu1", prelu: true}, {k: 3, stride: 2, same: true},
+		{name: "conv2"}, {name: "prelu2", prelu: true}, {k: 3, stride: 2},
+		{name: "conv3"}, {name: "prelu3", prelu: true}, {k: 2, stride: 2},
+		{name: "conv4"}, {name: "prelu4", prelu: true},
+		{name: "conv5"}, {name: "prelu5", prelu: true},
+	}
+	mtcnnHeads = map[string][]string{
+		"pnet": {"conv4-1", "conv4-2"},
+		"rnet": {"conv5-1", "conv5-2"},
+		"onet": {"conv6-1", "conv6-2", "conv6-3"},
+	}
+)
+
+// goMTCNN runs the MTCNN networks in Go with the weights of a frozen graph
+type goMTCNN struct {
+	weights map[string]*goTensor
+}
+
+func newGoMTCNN(g *graphDef) (*goMTCNN, error) {
+	m := &goMTCNN{weights: map[string]*goTensor{}}
+	load := func(name string) error {
+		t, err := g.constant(name)
+		if err != nil {
+			return fmt.Errorf("mtcnn weights: %v", err)
+		}
+		m.weights[name] = t
+		return nil
+	}
+	nets := map[string][]mtcnnLayer{"pnet": pnetLayers, "rnet": rnetLayers, "onet": onetLayers}
+	for net, layers := range nets {
+		for _, l := range layers {
+			if l.name == "" {
+				continue
+			}
+			if err := load(net + "/" + l.name + "/weights"); err != nil {
+				return nil, err
+			}
+			if !l.prelu {
+				if err := load(net + "/" + l.name + "/biases"); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, head := range mtcnnHeads[net] {
+			if err := load(net + "/" + head + "/weights"); err != nil {
+				return nil, err
+			}
+			if err := load(net + "/" + head + "/biases"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// conv applies the convolution and bias of the layer name
+func (m *goMTCNN) conv(x *goTensor, name string) (*goTensor, error) {
+	x, err := conv2d(x, m.weights[name+"/weights"], 1, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	return biasAdd(x, m.weights[name+"/biases"])
+}
+
+// run runs the layers of net on x and returns the outputs of its heads
+func (m *goMTCNN) run(net string, layers []mtcnnLayer, x *goTensor) ([]*goTensor, error) {
+	var err error
+	for _, l := range layers {
+		switch {
+		case l.name == "":
+			x, err = pool2d(x, l.k, l.k, l.stride, l.stride, l.same, false)
+		case l.prelu:
+			x, err = prelu(x, m.weights[net+"/"+l.name+"/weights"])
+		default:
+			x, err = m.conv(x, net+"/"+l.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	heads := mtcnnHeads[net]
+	res := make([]*goTensor, len(heads))
+	for i, head := range heads {
+		if res[i], err = m.conv(x, net+"/"+head); err != nil {
+			return nil, err
+		}
+	}
+	softmax(res[0])
+	return res, nil
+}
+
+func (m *goMTCNN) pnet(img *goTensor) (prob, reg *goTensor, err error) {
+	res, err := m.run("pnet", pnetLayers, img)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0], res[1], nil
+}
+
+func (m *goMTCNN) rnet(crops *goTensor) (prob, reg *goTensor, err error) {
+	res, err := m.run("rnet", rnetLayers, crops)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0], res[1], nil
+}
+
+func (m *goMTCNN) onet(crops *goTensor) (prob, reg, landmarks *goTensor, err error) {
+	res, err := m.run("onet", onetLayers, crops)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return res[0], res[1], res[2], nil
+}
+
+func (m *goMTCNN) close() {}
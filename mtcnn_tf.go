@@ -0,0 +1,186 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// LoadCascadeFaceModel loads an MTCNN model whose P-Net, R-Net and O-Net are
+// built as separate graphs from the weights of the frozen graph.
+func (tensorflowBackend) LoadCascadeFaceModel(def []byte, options ModelOptions) (CascadeFaceModel, error) {
+	g, err := parseGraphDef(def)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := newGoMTCNN(g)
+	if err != nil {
+		return nil, err
+	}
+	nets, err := newTFMTCNN(weights, options.Session)
+	if err != nil {
+		return nil, err
+	}
+	return &cascadeModel{nets: nets}, nil
+}
+
+// tfMTCNN runs the MTCNN networks as separate tensorflow graphs
+type tfMTCNN struct {
+	graph   *tf.Graph
+	session *tf.Session
+	inputs  map[string]tf.Output
+	outputs map[string][]tf.Output
+}
+
+func newTFMTCNN(weights *goMTCNN, options SessionOptions) (*tfMTCNN, error) {
+	m := &tfMTCNN{inputs: map[string]tf.Output{}, outputs: map[string][]tf.Output{}}
+	s := op.NewScope()
+
+	var err error
+	constant := func(s *op.Scope, name string) tf.Output {
+		t, e := weights.weights[name].tfTensor()
+		if e != nil {
+			err = e
+		}
+		return op.Const(s, t)
+	}
+	conv := func(s *op.Scope, x tf.Output, name string) tf.Output {
+		x = op.Conv2D(s, x, constant(s, name+"/weights"), []int64{1, 1, 1, 1}, "VALID")
+		return op.BiasAdd(s, x, constant(s, name+"/biases"))
+	}
+
+	nets := map[string][]mtcnnLayer{"pnet": pnetLayers, "rnet": rnetLayers, "onet": onetLayers}
+	for net, layers := range nets {
+		ns := s.SubScope(net)
+		x := op.Placeholder(ns.SubScope("input"), tf.Float, op.PlaceholderShape(tf.MakeShape(-1, -1, -1, 3)))
+		m.inputs[net] = x
+		for _, l := range layers {
+			switch {
+			case l.name == "":
+				padding := "VALID"
+				if l.same {
+					padding = "SAME"
+				}
+				k, stride := int64(l.k), int64(l.stride)
+				x = op.MaxPool(ns, x, []int64{1, k, k, 1}, []int64{1, stride, stride, 1}, padding)
+			case l.prelu:
+				ls := ns.SubScope(l.name)
+				alpha := constant(ls, net+"/"+l.name+"/weights")
+				x = op.Select(ls, op.Greater(ls, x, op.Const(ls, float32(0))), x, op.Mul(ls, x, alpha))
+			default:
+				x = conv(ns.SubScope(l.name), x, net+"/"+l.name)
+			}
+		}
+		for i, head := range mtcnnHeads[net] {
+			out := conv(ns.SubScope(head), x, net+"/"+head)
+			if i == 0 {
+				out = op.Softmax(ns, out)
+			}
+			m.outputs[net] = append(m.outputs[net], out)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mtcnn weights: %v", err)
+	}
+
+	if m.graph, err = s.Finalize(); err != nil {
+		return nil, err
+	}
+	if m.session, err = tf.NewSession(m.graph, options.tfOptions()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// run feeds x to the network net and returns its outputs
+func (m *tfMTCNN) run(net string, x *goTensor) ([]*goTensor, error) {
+	if m.session == nil {
+		return nil, errModelClosed
+	}
+	t, err := x.tfTensor()
+	if err != nil {
+		return nil, err
+	}
+	values, err := m.session.Run(map[tf.Output]*tf.Tensor{m.inputs[net]: t}, m.outputs[net], nil)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*goTensor, len(values))
+	for i, v := range values {
+		if res[i], err = goTensorFromTF(v); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (m *tfMTCNN) pnet(img *goTensor) (prob, reg *goTensor, err error) {
+	res, err := m.run("pnet", img)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0], res[1], nil
+}
+
+func (m *tfMTCNN) rnet(crops *goTensor) (prob, reg *goTensor, err error) {
+	res, err := m.run("rnet", crops)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0], res[1], nil
+}
+
+func (m *tfMTCNN) onet(crops *goTensor) (prob, reg, landmarks *goTensor, err error) {
+	res, err := m.run("onet", crops)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return res[0], res[1], res[2], nil
+}
+
+func (m *tfMTCNN) close() {
+	if m.session != nil {
+		m.session.Close()
+		m.graph = nil
+		m.session = nil
+	}
+}
+
+// tfTensor converts the goTensor to a float32 tensorflow tensor
+func (t *goTensor) tfTensor() (*tf.Tensor, error) {
+	shape := make([]int64, len(t.shape))
+	for i, d := range t.shape {
+		shape[i] = int64(d)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(t.data)*4))
+	if err := binary.Write(buf, binary.LittleEndian, t.data); err != nil {
+		return nil, err
+	}
+	return tf.ReadTensor(tf.Float, shape, buf)
+}
+
+// goTensorFromTF converts a float32 tensorflow tensor to a goTensor
+func goTensorFromTF(t *tf.Tensor) (*goTensor, error) {
+	if t.DataType() != tf.Float {
+		return nil, fmt.Errorf("tensor type %s, expected float32", dataTypeName(t.DataType()))
+	}
+	shape := make([]int, len(t.Shape()))
+	for i, d := range t.Shape() {
+		shape[i] = int(d)
+	}
+	res := newGoTensor(shape...)
+	var buf bytes.Buffer
+	if _, err := t.WriteContentsTo(&buf); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(&buf, binary.LittleEndian, res.data); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
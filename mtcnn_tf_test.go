@@ -0,0 +1,45 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import "testing"
+
+// Tolerances of the cascade mode against the fused MTCNN graph
+const (
+	cascadeMinIoU       = 0.9
+	cascadeMaxProbDelta = 0.01
+)
+
+func TestCascadeMatchesFusedGraph(t *testing.T) {
+	def := readModel(t, "mtcnn_1.14.pb")
+	fused, err := NewFaceDetectorFromBytes(def, FaceDetectorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fused.Close()
+	cascade, err := NewFaceDetectorFromBytes(def, FaceDetectorOptions{Cascade: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cascade.Close()
+
+	for name, img := range testImages(t) {
+		tensor, err := NewImageData(img).tensor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := fused.DetectFaces(tensor)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, stages, err := cascade.DetectStages(img)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(stages) != 3 {
+			t.Errorf("%s: %d stages, want 3", name, len(stages))
+		}
+		compareFaces(t, name, want.results, got.results, cascadeMinIoU, cascadeMaxProbDelta)
+	}
+}
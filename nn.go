@@ -0,0 +1,479 @@
+package tfimage
+
+// Neural network layers of the GoBackend. Image tensors are in NHWC layout
+// and layers follow the semantics of the tensorflow 1.14 kernels.
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// goTensor - A dense float32 tensor in row major order
+type goTensor struct {
+	shape []int
+	data  []float32
+}
+
+func newGoTensor(shape ...int) *goTensor {
+	return &goTensor{shape: shape, data: make([]float32, shapeSize(shape))}
+}
+
+func shapeSize(shape []int) int {
+	n := 1
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+// dim returns the size of dimension i, counted from the end when negative
+func (t *goTensor) dim(i int) int {
+	if i < 0 {
+		i += len(t.shape)
+	}
+	return t.shape[i]
+}
+
+func (t *goTensor) checkRank(rank int, layer string) error {
+	if len(t.shape) != rank {
+		return fmt.Errorf("%s: input shape %v, expected rank %d", layer, t.shape, rank)
+	}
+	return nil
+}
+
+// parallel calls fn for 0 <= i < n from GOMAXPROCS goroutines
+func parallel(n int, fn func(i int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	next := make(chan int, n)
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// window returns the output size and the padding before the input of a
+// convolution or pooling window, with tensorflow's SAME or VALID padding.
+func window(in, k, stride int, same bool) (out, pad int) {
+	if same {
+		out = (in + stride - 1) / stride
+		if total := (out-1)*stride + k - in; total > 0 {
+			pad = total / 2
+		}
+		return out, pad
+	}
+	if in < k {
+		return 0, 0
+	}
+	return (in-k)/stride + 1, 0
+}
+
+// conv2d convolves x [N,H,W,Cin] with the filter w [KH,KW,Cin,Cout]
+func conv2d(x, w *goTensor, strideY, strideX int, same bool) (*goTensor, error) {
+	if err := x.checkRank(4, "conv2d"); err != nil {
+		return nil, err
+	}
+	if len(w.shape) != 4 || w.shape[2] != x.shape[3] {
+		return nil, fmt.Errorf("conv2d: filter shape %v does not match input shape %v", w.shape, x.shape)
+	}
+	n, h, wd, cin := x.shape[0], x.shape[1], x.shape[2], x.shape[3]
+	kh, kw, cout := w.shape[0], w.shape[1], w.shape[3]
+	oh, padY := window(h, kh, strideY, same)
+	ow, padX := window(wd, kw, strideX, same)
+	out := newGoTensor(n, oh, ow, cout)
+
+	parallel(n*oh, func(row int) {
+		b, oy := row/oh, row%oh
+		for ox := 0; ox < ow; ox++ {
+			acc := out.data[((b*oh+oy)*ow+ox)*cout:][:cout]
+			for ky := 0; ky < kh; ky++ {
+				iy := oy*strideY + ky - padY
+				if iy < 0 || iy >= h {
+					continue
+				}
+				for kx := 0; kx < kw; kx++ {
+					ix := ox*strideX + kx - padX
+					if ix < 0 || ix >= wd {
+						continue
+					}
+					px := x.data[((b*h+iy)*wd+ix)*cin:][:cin]
+					filter := w.data[(ky*kw+kx)*cin*cout:]
+					for ci, v := range px {
+						f := filter[ci*cout:][:cout]
+						for co := range acc {
+							acc[co] += v * f[co]
+						}
+					}
+				}
+			}
+		}
+	})
+	return out, nil
+}
+
+// depthwiseConv2d convolves each channel of x [N,H,W,C] with the filter w [KH,KW,C,M]
+func depthwiseConv2d(x, w *goTensor, strideY, strideX int, same bool) (*goTensor, error) {
+	if err := x.checkRank(4, "depthwise conv2d"); err != nil {
+		return nil, err
+	}
+	if len(w.shape) != 4 || w.shape[2] != x.shape[3] {
+		return nil, fmt.Errorf("depthwise conv2d: filter shape %v does not match input shape %v", w.shape, x.shape)
+	}
+	n, h, wd, c := x.shape[0], x.shape[1], x.shape[2], x.shape[3]
+	kh, kw, m := w.shape[0], w.shape[1], w.shape[3]
+	oh, padY := window(h, kh, strideY, same)
+	ow, padX := window(wd, kw, strideX, same)
+	out := newGoTensor(n, oh, ow, c*m)
+
+	parallel(n*oh, func(row int) {
+		b, oy := row/oh, row%oh
+		for ox := 0; ox < ow; ox++ {
+			acc := out.data[((b*oh+oy)*ow+ox)*c*m:][:c*m]
+			for ky := 0; ky < kh; ky++ {
+				iy := oy*strideY + ky - padY
+				if iy < 0 || iy >= h {
+					continue
+				}
+				for kx := 0; kx < kw; kx++ {
+					ix := ox*strideX + kx - padX
+					if ix < 0 || ix >= wd {
+						continue
+					}
+					px := x.data[((b*h+iy)*wd+ix)*c:][:c]
+					filter := w.data[(ky*kw+kx)*c*m:][:c*m]
+					for i := range acc {
+						acc[i] += px[i/m] * filter[i]
+					}
+				}
+			}
+		}
+	})
+	return out, nil
+}
+
+// pool2d applies a max or average pooling window to x [N,H,W,C].
+// Padded positions are excluded from both.
+func pool2d(x *goTensor, kh, kw, strideY, strideX int, same, average bool) (*goTensor, error) {
+	if err := x.checkRank(4, "pool"); err != nil {
+		return nil, err
+	}
+	n, h, wd, c := x.shape[0], x.shape[1], x.shape[2], x.shape[3]
+	oh, padY := window(h, kh, strideY, same)
+	ow, padX := window(wd, kw, strideX, same)
+	out := newGoTensor(n, oh, ow, c)
+
+	parallel(n*oh, func(row int) {
+		b, oy := row/oh, row%oh
+		for ox := 0; ox < ow; ox++ {
+			acc := out.data[((b*oh+oy)*ow+ox)*c:][:c]
+			if !average {
+				for i := range acc {
+					acc[i] = float32(math.Inf(-1))
+				}
+			}
+			count := 0
+			for ky := 0; ky < kh; ky++ {
+				iy := oy*strideY + ky - padY
+				if iy < 0 || iy >= h {
+					continue
+				}
+				for kx := 0; kx < kw; kx++ {
+					ix := ox*strideX + kx - padX
+					if ix < 0 || ix >= wd {
+						continue
+					}
+					count++
+					px := x.data[((b*h+iy)*wd+ix)*c:][:c]
+					for i, v := range px {
+						if average {
+							acc[i] += v
+						} else if v > acc[i] {
+							acc[i] = v
+						}
+					}
+				}
+			}
+			if average && count > 0 {
+				for i := range acc {
+					acc[i] /= float32(count)
+				}
+			}
+		}
+	})
+	return out, nil
+}
+
+// biasAdd adds b to the last dimension of x in place
+func biasAdd(x, b *goTensor) (*goTensor, error) {
+	c := len(b.data)
+	if len(x.shape) == 0 || x.dim(-1) != c {
+		return nil, fmt.Errorf("bias add: bias shape %v does not match input shape %v", b.shape, x.shape)
+	}
+	for i := range x.data {
+		x.data[i] += b.data[i%c]
+	}
+	return x, nil
+}
+
+// prelu computes max(x, 0) + alpha * min(x, 0) in place, with alpha broadcast along the last dimension
+func prelu(x, alpha *goTensor) (*goTensor, error) {
+	c := len(alpha.data)
+	if len(x.shape) == 0 || x.dim(-1) != c {
+		return nil, fmt.Errorf("prelu: alpha shape %v does not match input shape %v", alpha.shape, x.shape)
+	}
+	for i, v := range x.data {
+		if v <= 0 {
+			x.data[i] = v * alpha.data[i%c]
+		}
+	}
+	return x, nil
+}
+
+// softmax normalizes the last dimension of x in place
+func softmax(x *goTensor) *goTensor {
+	c := x.dim(-1)
+	for i := 0; c > 0 && i+c <= len(x.data); i += c {
+		row := x.data[i : i+c]
+		max := row[0]
+		for _, v := range row {
+			if v > max {
+				max = v
+			}
+		}
+		var sum float32
+		for j, v := range row {
+			row[j] = float32(math.Exp(float64(v - max)))
+			sum += row[j]
+		}
+		for j := range row {
+			row[j] /= sum
+		}
+	}
+	return x
+}
+
+// matMul multiplies the matrices a [M,K] and b [K,N], optionally transposed
+func matMul(a, b *goTensor, transposeA, transposeB bool) (*goTensor, error) {
+	if len(a.shape) != 2 || len(b.shape) != 2 {
+		return nil, fmt.Errorf("matmul: shapes %v and %v are not matrices", a.shape, b.shape)
+	}
+	m, k := a.shape[0], a.shape[1]
+	if transposeA {
+		m, k = k, m
+	}
+	kb, n := b.shape[0], b.shape[1]
+	if transposeB {
+		kb, n = n, kb
+	}
+	if k != kb {
+		return nil, fmt.Errorf("matmul: shapes %v and %v do not match", a.shape, b.shape)
+	}
+	at := func(i, j int) float32 {
+		if transposeA {
+			return a.data[j*m+i]
+		}
+		return a.data[i*k+j]
+	}
+	bt := func(i, j int) float32 {
+		if transposeB {
+			return b.data[j*k+i]
+		}
+		return b.data[i*n+j]
+	}
+
+	out := newGoTensor(m, n)
+	parallel(m, func(i int) {
+		row := out.data[i*n:][:n]
+		for j := 0; j < k; j++ {
+			v := at(i, j)
+			for c := range row {
+				row[c] += v * bt(j, c)
+			}
+		}
+	})
+	return out, nil
+}
+
+// resizeBilinear resizes x [N,H,W,C] to [N,oh,ow,C], with align_corners and half_pixel_centers disabled
+func resizeBilinear(x *goTensor, oh, ow int) (*goTensor, error) {
+	if err := x.checkRank(4, "resize bilinear"); err != nil {
+		return nil, err
+	}
+	if oh <= 0 || ow <= 0 {
+		return nil, fmt.Errorf("resize bilinear: invalid size %dx%d", ow, oh)
+	}
+	n, h, w, c := x.shape[0], x.shape[1], x.shape[2], x.shape[3]
+	sy, sx := float32(h)/float32(oh), float32(w)/float32(ow)
+	out := newGoTensor(n, oh, ow, c)
+
+	parallel(n*oh, func(row int) {
+		b, oy := row/oh, row%oh
+		in := float32(oy) * sy
+		y0 := int(in)
+		y1 := minInt(y0+1, h-1)
+		dy := in - float32(y0)
+		for ox := 0; ox < ow; ox++ {
+			in := float32(ox) * sx
+			x0 := int(in)
+			x1 := minInt(x0+1, w-1)
+			dx := in - float32(x0)
+			bilinear(out.data[((b*oh+oy)*ow+ox)*c:][:c], x.data[b*h*w*c:], w, c, y0, y1, x0, x1, dy, dx)
+		}
+	})
+	return out, nil
+}
+
+// bilinear interpolates the pixels of img with width w and c channels into px
+func bilinear(px, img []float32, w, c, y0, y1, x0, x1 int, dy, dx float32) {
+	tl, tr := img[(y0*w+x0)*c:], img[(y0*w+x1)*c:]
+	bl, br := img[(y1*w+x0)*c:], img[(y1*w+x1)*c:]
+	for i := range px {
+		top := tl[i] + (tr[i]-tl[i])*dx
+		bottom := bl[i] + (br[i]-bl[i])*dx
+		px[i] = top + (bottom-top)*dy
+	}
+}
+
+// cropAndResize crops the boxes [y1,x1,y2,x2], normalized to the size of
+// image x [1,H,W,C], and resizes them bilinearly to [len(boxes),size,size,C].
+// Points outside of the image are 0.
+func cropAndResize(x *goTensor, boxes [][4]float32, size int) (*goTensor, error) {
+	if err := x.checkRank(4, "crop and resize"); err != nil {
+		return nil, err
+	}
+	h, w, c := x.shape[1], x.shape[2], x.shape[3]
+	out := newGoTensor(len(boxes), size, size, c)
+
+	scale := func(a, b float32, dim int) float32 {
+		if size > 1 {
+			return (b - a) * float32(dim-1) / float32(size-1)
+		}
+		return 0
+	}
+	parallel(len(boxes)*size, func(row int) {
+		b, oy := row/size, row%size
+		box := boxes[b]
+		in := box[0]*float32(h-1) + float32(oy)*scale(box[0], box[2], h)
+		if size == 1 {
+			in = 0.5 * (box[0] + box[2]) * float32(h-1)
+		}
+		if in < 0 || in > float32(h-1) {
+			return
+		}
+		y0 := int(math.Floor(float64(in)))
+		y1 := int(math.Ceil(float64(in)))
+		dy := in - float32(y0)
+		for ox := 0; ox < size; ox++ {
+			in := box[1]*float32(w-1) + float32(ox)*scale(box[1], box[3], w)
+			if size == 1 {
+				in = 0.5 * (box[1] + box[3]) * float32(w-1)
+			}
+			if in < 0 || in > float32(w-1) {
+				continue
+			}
+			x0 := int(math.Floor(float64(in)))
+			x1 := int(math.Ceil(float64(in)))
+			bilinear(out.data[((b*size+oy)*size+ox)*c:][:c], x.data, w, c, y0, y1, x0, x1, dy, in-float32(x0))
+		}
+	})
+	return out, nil
+}
+
+// nonMaxSuppression greedily selects boxes [y1,x1,y2,x2] by descending score,
+// skipping boxes that overlap a selected box by more than iou. It returns at
+// most max indices of the selected boxes.
+func nonMaxSuppression(boxes [][4]float32, scores []float32, iou float32, max int) []int {
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	area := func(b [4]float32) float32 {
+		return float32(math.Abs(float64((b[2] - b[0]) * (b[3] - b[1]))))
+	}
+	overlap := func(a, b [4]float32) float32 {
+		ay1, ay2 := minMax(a[0], a[2])
+		ax1, ax2 := minMax(a[1], a[3])
+		by1, by2 := minMax(b[0], b[2])
+		bx1, bx2 := minMax(b[1], b[3])
+		ih := minFloat(ay2, by2) - maxFloat(ay1, by1)
+		iw := minFloat(ax2, bx2) - maxFloat(ax1, bx1)
+		if ih <= 0 || iw <= 0 {
+			return 0
+		}
+		union := area(a) + area(b) - ih*iw
+		if union <= 0 {
+			return 0
+		}
+		return ih * iw / union
+	}
+
+	var keep []int
+	for _, i := range order {
+		if len(keep) >= max {
+			break
+		}
+		ok := true
+		for _, k := range keep {
+			if overlap(boxes[i], boxes[k]) > iou {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			keep = append(keep, i)
+		}
+	}
+	return keep
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func minFloat(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minMax(a, b float32) (float32, float32) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
@@ -0,0 +1,186 @@
+package tfimage
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+const nnTolerance = 1e-5
+
+// seq returns a tensor of shape filled with start, start+1, ...
+func seq(start float32, shape ...int) *goTensor {
+	t := newGoTensor(shape...)
+	for i := range t.data {
+		t.data[i] = start + float32(i)
+	}
+	return t
+}
+
+func tensorOf(data []float32, shape ...int) *goTensor {
+	return &goTensor{shape: shape, data: data}
+}
+
+func checkTensor(t *testing.T, name string, got *goTensor, err error, shape []int, data []float32) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	if !reflect.DeepEqual(got.shape, shape) {
+		t.Errorf("%s: shape %v, want %v", name, got.shape, shape)
+		return
+	}
+	for i, v := range data {
+		if math.Abs(float64(got.data[i]-v)) > nnTolerance {
+			t.Errorf("%s: %v, want %v", name, got.data, data)
+			return
+		}
+	}
+}
+
+func TestConv2D(t *testing.T) {
+	x := seq(1, 1, 3, 3, 1)
+	// adds each pixel to the pixel below and to the right
+	diag := tensorOf([]float32{1, 0, 0, 1}, 2, 2, 1, 1)
+
+	out, err := conv2d(x, diag, 1, 1, false)
+	checkTensor(t, "valid", out, err, []int{1, 2, 2, 1}, []float32{6, 8, 12, 14})
+
+	// SAME pads the extra row and column after the input, as tensorflow does
+	out, err = conv2d(x, diag, 1, 1, true)
+	checkTensor(t, "same", out, err, []int{1, 3, 3, 1}, []float32{6, 8, 3, 12, 14, 6, 7, 8, 9})
+
+	out, err = conv2d(x, diag, 2, 2, false)
+	checkTensor(t, "stride 2", out, err, []int{1, 1, 1, 1}, []float32{6})
+
+	// filter [KH,KW,Cin,Cout] mixes the channels
+	out, err = conv2d(tensorOf([]float32{1, 2}, 1, 1, 1, 2), tensorOf([]float32{1, 2, 3, 4}, 1, 1, 2, 2), 1, 1, false)
+	checkTensor(t, "channels", out, err, []int{1, 1, 1, 2}, []float32{7, 10})
+
+	if _, err = conv2d(x, tensorOf([]float32{1, 1}, 1, 1, 2, 1), 1, 1, false); err == nil {
+		t.Error("mismatched filter: expected an error")
+	}
+}
+
+func TestDepthwiseConv2D(t *testing.T) {
+	x := tensorOf([]float32{1, 10, 2, 20, 3, 30, 4, 40}, 1, 2, 2, 2)
+	w := tensorOf([]float32{1, 0.5, 1, 0.5, 1, 0.5, 1, 0.5}, 2, 2, 2, 1)
+	out, err := depthwiseConv2d(x, w, 1, 1, false)
+	checkTensor(t, "depthwise", out, err, []int{1, 1, 1, 2}, []float32{10, 50})
+}
+
+func TestPool2D(t *testing.T) {
+	out, err := pool2d(seq(1, 1, 4, 4, 1), 2, 2, 2, 2, false, false)
+	checkTensor(t, "max valid", out, err, []int{1, 2, 2, 1}, []float32{6, 8, 14, 16})
+
+	// a 3x3 window with stride 2 pads one pixel on each side of a 3x3 input
+	x := seq(1, 1, 3, 3, 1)
+	out, err = pool2d(x, 3, 3, 2, 2, true, false)
+	checkTensor(t, "max same", out, err, []int{1, 2, 2, 1}, []float32{5, 6, 8, 9})
+
+	// padded positions are not counted in the average
+	out, err = pool2d(x, 3, 3, 2, 2, true, true)
+	checkTensor(t, "average same", out, err, []int{1, 2, 2, 1}, []float32{3, 4, 6, 7})
+
+	out, err = pool2d(tensorOf([]float32{-1, -5, -3, -2}, 1, 2, 2, 1), 2, 2, 2, 2, false, false)
+	checkTensor(t, "max negative", out, err, []int{1, 1, 1, 1}, []float32{-1})
+}
+
+func TestPReLU(t *testing.T) {
+	out, err := prelu(tensorOf([]float32{-2, 3, -4, 5, 0, -8}, 3, 2), tensorOf([]float32{0.5, 0.25}, 2))
+	checkTensor(t, "prelu", out, err, []int{3, 2}, []float32{-1, 3, -2, 5, 0, -2})
+
+	if _, err = prelu(seq(0, 2, 3), tensorOf([]float32{1, 1}, 2)); err == nil {
+		t.Error("mismatched alpha: expected an error")
+	}
+}
+
+func TestBiasAdd(t *testing.T) {
+	out, err := biasAdd(seq(0, 2, 2), tensorOf([]float32{10, 20}, 2))
+	checkTensor(t, "bias add", out, err, []int{2, 2}, []float32{10, 21, 12, 23})
+}
+
+func TestSoftmax(t *testing.T) {
+	out := softmax(tensorOf([]float32{1, 1, 0, float32(math.Log(3)), 1000, 1000}, 3, 2))
+	checkTensor(t, "softmax", out, nil, []int{3, 2}, []float32{0.5, 0.5, 0.25, 0.75, 0.5, 0.5})
+}
+
+func TestMatMul(t *testing.T) {
+	want := []float32{58, 64, 139, 154}
+	a, b := seq(1, 2, 3), seq(7, 3, 2)
+	out, err := matMul(a, b, false, false)
+	checkTensor(t, "matmul", out, err, []int{2, 2}, want)
+
+	at := tensorOf([]float32{1, 4, 2, 5, 3, 6}, 3, 2)
+	bt := tensorOf([]float32{7, 9, 11, 8, 10, 12}, 2, 3)
+	out, err = matMul(at, bt, true, true)
+	checkTensor(t, "transposed", out, err, []int{2, 2}, want)
+	out, err = matMul(at, b, true, false)
+	checkTensor(t, "transpose a", out, err, []int{2, 2}, want)
+	out, err = matMul(a, bt, false, true)
+	checkTensor(t, "transpose b", out, err, []int{2, 2}, want)
+
+	if _, err = matMul(a, a, false, false); err == nil {
+		t.Error("mismatched shapes: expected an error")
+	}
+}
+
+func TestResizeBilinear(t *testing.T) {
+	// v(y, x) = 2y + x, sampled at in = out * in_size / out_size and clamped to the last pixel
+	out, err := resizeBilinear(seq(0, 1, 2, 2, 1), 4, 4)
+	pos := []float32{0, 0.5, 1, 1}
+	var want []float32
+	for _, y := range pos {
+		for _, x := range pos {
+			want = append(want, 2*y+x)
+		}
+	}
+	checkTensor(t, "upscale", out, err, []int{1, 4, 4, 1}, want)
+
+	out, err = resizeBilinear(seq(0, 1, 4, 4, 1), 2, 2)
+	checkTensor(t, "downscale", out, err, []int{1, 2, 2, 1}, []float32{0, 2, 8, 10})
+}
+
+func TestCropAndResize(t *testing.T) {
+	x := seq(0, 1, 3, 3, 1)
+	out, err := cropAndResize(x, [][4]float32{
+		{0, 0, 1, 1},         // the whole image
+		{0, 0, 0.5, 0.5},     // top left quarter
+		{-1, -1, -0.5, -0.5}, // outside of the image
+	}, 3)
+	checkTensor(t, "crop and resize", out, err, []int{3, 3, 3, 1}, []float32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8,
+		0, 0.5, 1, 1.5, 2, 2.5, 3, 3.5, 4,
+		0, 0, 0, 0, 0, 0, 0, 0, 0,
+	})
+
+	out, err = cropAndResize(x, [][4]float32{{0, 0, 1, 1}}, 1)
+	checkTensor(t, "size 1", out, err, []int{1, 1, 1, 1}, []float32{4})
+}
+
+func TestNonMaxSuppression(t *testing.T) {
+	boxes := [][4]float32{
+		{0, 0, 10, 10},   // 0
+		{1, 1, 11, 11},   // 1, IoU 81/119 with 0
+		{20, 20, 30, 30}, // 2
+		{10, 10, 0, 0},   // 3, box 0 with flipped corners
+	}
+	scores := []float32{0.9, 0.8, 0.95, 0.5}
+	tests := []struct {
+		iou  float32
+		max  int
+		want []int
+	}{
+		{0.5, 10, []int{2, 0}},
+		{0.7, 10, []int{2, 0, 1}},
+		{1, 10, []int{2, 0, 1, 3}},
+		{0.5, 1, []int{2}},
+		{0.5, 0, nil},
+	}
+	for _, tt := range tests {
+		if got := nonMaxSuppression(boxes, scores, tt.iou, tt.max); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("nonMaxSuppression(iou %v, max %d) = %v, want %v", tt.iou, tt.max, got, tt.want)
+		}
+	}
+}
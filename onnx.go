@@ -0,0 +1,305 @@
+//go:build cgo && onnxruntime
+// +build cgo,onnxruntime
+
+package tfimage
+
+/*
+#cgo LDFLAGS: -lonnxruntime
+#include <stdlib.h>
+#include <string.h>
+#include "onnxruntime_c_api.h"
+
+static const OrtApi* ort;
+
+static char* ort_error(OrtStatus* s) {
+	if (s == NULL) return NULL;
+	char* msg = strdup(ort->GetErrorMessage(s));
+	ort->ReleaseStatus(s);
+	return msg;
+}
+
+static char* ort_init(OrtEnv** env) {
+	if (ort == NULL) ort = OrtGetApiBase()->GetApi(ORT_API_VERSION);
+	if (ort == NULL) return strdup("unsupported onnxruntime API version");
+	return ort_error(ort->CreateEnv(ORT_LOGGING_LEVEL_WARNING, "tfimage", env));
+}
+
+static char* ort_create_session(OrtEnv* env, const void* data, size_t len, int intra, int inter, OrtSession** session) {
+	OrtSessionOptions* so;
+	char* err = ort_error(ort->CreateSessionOptions(&so));
+	if (err) return err;
+	if (intra > 0) err = ort_error(ort->SetIntraOpNumThreads(so, intra));
+	if (!err && inter > 0) err = ort_error(ort->SetInterOpNumThreads(so, inter));
+	if (!err) err = ort_error(ort->CreateSessionFromArray(env, data, len, so, session));
+	ort->ReleaseSessionOptions(so);
+	return err;
+}
+
+static char* ort_count(OrtSession* s, int output, size_t* n) {
+	return ort_error(output ? ort->SessionGetOutputCount(s, n) : ort->SessionGetInputCount(s, n));
+}
+
+static char* ort_name(OrtSession* s, int output, size_t i, char** name) {
+	OrtAllocator* a;
+	char* err = ort_error(ort->GetAllocatorWithDefaultOptions(&a));
+	if (err) return err;
+	char* n;
+	err = ort_error(output ? ort->SessionGetOutputName(s, i, a, &n) : ort->SessionGetInputName(s, i, a, &n));
+	if (err) return err;
+	*name = strdup(n);
+	return ort_error(ort->AllocatorFree(a, n));
+}
+
+static char* ort_input_dims(OrtSession* s, size_t i, int64_t* dims, size_t max, size_t* n) {
+	OrtTypeInfo* ti;
+	char* err = ort_error(ort->SessionGetInputTypeInfo(s, i, &ti));
+	if (err) return err;
+	const OrtTensorTypeAndShapeInfo* info;
+	err = ort_error(ort->CastTypeInfoToTensorInfo(ti, &info));
+	if (!err) err = ort_error(ort->GetDimensionsCount(info, n));
+	if (!err && *n > max) err = strdup("input rank is too large");
+	if (!err) err = ort_error(ort->GetDimensions(info, dims, *n));
+	ort->ReleaseTypeInfo(ti);
+	return err;
+}
+
+static char* ort_run(OrtSession* s, const char* input, float* data, size_t len, const int64_t* shape, size_t rank,
+	const char** outputs, size_t n, OrtValue** values) {
+	OrtMemoryInfo* mem;
+	char* err = ort_error(ort->CreateCpuMemoryInfo(OrtArenaAllocator, OrtMemTypeDefault, &mem));
+	if (err) return err;
+	OrtValue* in = NULL;
+	err = ort_error(ort->CreateTensorWithDataAsOrtValue(mem, data, len * sizeof(float), shape, rank,
+		ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT, &in));
+	if (!err) err = ort_error(ort->Run(s, NULL, &input, (const OrtValue* const*)&in, 1, outputs, n, values));
+	if (in) ort->ReleaseValue(in);
+	ort->ReleaseMemoryInfo(mem);
+	return err;
+}
+
+static char* ort_output(OrtValue* v, int64_t* dims, size_t max, size_t* n, float** data) {
+	OrtTensorTypeAndShapeInfo* info;
+	char* err = ort_error(ort->GetTensorTypeAndShape(v, &info));
+	if (err) return err;
+	ONNXTensorElementDataType t;
+	err = ort_error(ort->GetTensorElementType(info, &t));
+	if (!err && t != ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT) err = strdup("output is not float32");
+	if (!err) err = ort_error(ort->GetDimensionsCount(info, n));
+	if (!err && *n > max) err = strdup("output rank is too large");
+	if (!err) err = ort_error(ort->GetDimensions(info, dims, *n));
+	ort->ReleaseTensorTypeAndShapeInfo(info);
+	if (!err) err = ort_error(ort->GetTensorMutableData(v, (void**)data));
+	return err;
+}
+
+static void ort_release_value(OrtValue* v) { if (v) ort->ReleaseValue(v); }
+static void ort_release_session(OrtSession* s) { ort->ReleaseSession(s); }
+static void ort_release_env(OrtEnv* e) { ort->ReleaseEnv(e); }
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"unsafe"
+)
+
+// ONNXBackend runs ONNX models with the ONNX Runtime C API. It is built with
+// the "onnxruntime" build tag and links libonnxruntime.
+//
+// Face detection models are decoded to Faces according to Face. Only the MinimumSize
+// of FaceDetectorOptions applies to them: faces are selected with ScoreThreshold and
+// NMSThreshold, and the MTCNN scale factor and stage thresholds are ignored. Quality models
+// are fed 0-255 images and return the probabilities of the scores 1 to 10 as their
+// first output. Session.IntraOpThreads and Session.InterOpThreads size the thread pools.
+type ONNXBackend struct {
+	// Face is the format of face detection models
+	Face ONNXFaceFormat
+	// InputSize is the size images are resized to. SCRFD defaults to 640x640,
+	// and RetinaFace runs at the size of the image when it is zero.
+	InputSize image.Point
+	// ScoreThreshold defaults to DefaultONNXScoreThreshold
+	ScoreThreshold float32
+	// NMSThreshold defaults to DefaultONNXNMSThreshold
+	NMSThreshold float32
+}
+
+func (b ONNXBackend) LoadFaceModel(def []byte, options ModelOptions) (FaceModel, error) {
+	s, err := newORTSession(def, options.Session)
+	if err != nil {
+		return nil, err
+	}
+	m := &onnxFaceModel{session: s, format: b.Face, width: b.InputSize.X, height: b.InputSize.Y,
+		score: b.ScoreThreshold, nms: b.NMSThreshold}
+	if m.score <= 0 {
+		m.score = DefaultONNXScoreThreshold
+	}
+	if m.nms <= 0 {
+		m.nms = DefaultONNXNMSThreshold
+	}
+	return m, nil
+}
+
+func (b ONNXBackend) LoadAestheticsModel(def []byte, options ModelOptions) (AestheticsModel, error) {
+	s, err := newORTSession(def, options.Session)
+	if err != nil {
+		return nil, err
+	}
+	nchw := len(s.inputShape) == 4 && s.inputShape[1] == 3 && s.inputShape[3] != 3
+	return &onnxAestheticsModel{session: s, nchw: nchw}, nil
+}
+
+// ortMaxRank is the largest tensor rank read from a session
+const ortMaxRank = 8
+
+// ortSession is an ONNX Runtime session with its input and output names.
+// Sessions are safe for concurrent use.
+type ortSession struct {
+	mu         sync.RWMutex
+	env        *C.OrtEnv
+	session    *C.OrtSession
+	input      *C.char
+	outputs    []*C.char
+	inputShape []int64 // -1 for dynamic dimensions
+}
+
+func ortError(err *C.char) error {
+	if err == nil {
+		return nil
+	}
+	defer C.free(unsafe.Pointer(err))
+	return fmt.Errorf("onnxruntime: %s", C.GoString(err))
+}
+
+func newORTSession(def []byte, options SessionOptions) (*ortSession, error) {
+	if len(def) == 0 {
+		return nil, fmt.Errorf("onnxruntime: empty model")
+	}
+	s := &ortSession{}
+	if err := ortError(C.ort_init(&s.env)); err != nil {
+		return nil, err
+	}
+	data := C.CBytes(def)
+	defer C.free(data)
+	err := ortError(C.ort_create_session(s.env, data, C.size_t(len(def)),
+		C.int(options.IntraOpThreads), C.int(options.InterOpThreads), &s.session))
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	if err = s.describe(); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// describe reads the input and output names and the input shape of the session
+func (s *ortSession) describe() error {
+	var inputs, outputs C.size_t
+	if err := ortError(C.ort_count(s.session, 0, &inputs)); err != nil {
+		return err
+	}
+	if inputs != 1 {
+		return fmt.Errorf("onnxruntime: model has %d inputs, expected an image input", int(inputs))
+	}
+	if err := ortError(C.ort_name(s.session, 0, 0, &s.input)); err != nil {
+		return err
+	}
+	dims := make([]C.int64_t, ortMaxRank)
+	var rank C.size_t
+	if err := ortError(C.ort_input_dims(s.session, 0, &dims[0], ortMaxRank, &rank)); err != nil {
+		return err
+	}
+	for _, d := range dims[:rank] {
+		s.inputShape = append(s.inputShape, int64(d))
+	}
+	if err := ortError(C.ort_count(s.session, 1, &outputs)); err != nil {
+		return err
+	}
+	for i := 0; i < int(outputs); i++ {
+		var name *C.char
+		if err := ortError(C.ort_name(s.session, 1, C.size_t(i), &name)); err != nil {
+			return err
+		}
+		s.outputs = append(s.outputs, name)
+	}
+	return nil
+}
+
+func (s *ortSession) run(x *goTensor) ([]*goTensor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.session == nil {
+		return nil, errModelClosed
+	}
+	if len(x.data) == 0 {
+		return nil, fmt.Errorf("onnxruntime: empty input %v", x.shape)
+	}
+	// onnxruntime keeps the input memory during Run, so it is copied to C memory
+	data := C.malloc(C.size_t(len(x.data) * 4))
+	defer C.free(data)
+	copy((*[1 << 30]float32)(data)[:len(x.data):len(x.data)], x.data)
+	shape := make([]C.int64_t, len(x.shape))
+	for i, d := range x.shape {
+		shape[i] = C.int64_t(d)
+	}
+
+	n := len(s.outputs)
+	names := (*[1 << 20]*C.char)(C.malloc(C.size_t(n) * C.size_t(unsafe.Sizeof(uintptr(0)))))[:n:n]
+	defer C.free(unsafe.Pointer(&names[0]))
+	copy(names, s.outputs)
+	values := (*[1 << 20]*C.OrtValue)(C.calloc(C.size_t(n), C.size_t(unsafe.Sizeof(uintptr(0)))))[:n:n]
+	defer C.free(unsafe.Pointer(&values[0]))
+	defer func() {
+		for _, v := range values {
+			C.ort_release_value(v)
+		}
+	}()
+
+	err := ortError(C.ort_run(s.session, s.input, (*C.float)(data), C.size_t(len(x.data)),
+		&shape[0], C.size_t(len(shape)), &names[0], C.size_t(n), &values[0]))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*goTensor, n)
+	dims := make([]C.int64_t, ortMaxRank)
+	for i, v := range values {
+		var rank C.size_t
+		var out *C.float
+		if err = ortError(C.ort_output(v, &dims[0], ortMaxRank, &rank, &out)); err != nil {
+			return nil, err
+		}
+		shape := make([]int, rank)
+		for j := range shape {
+			shape[j] = int(dims[j])
+		}
+		res[i] = newGoTensor(shape...)
+		if size := len(res[i].data); size > 0 {
+			copy(res[i].data, (*[1 << 30]float32)(unsafe.Pointer(out))[:size:size])
+		}
+	}
+	return res, nil
+}
+
+func (s *ortSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session != nil {
+		C.ort_release_session(s.session)
+		s.session = nil
+	}
+	if s.env != nil {
+		C.ort_release_env(s.env)
+		s.env = nil
+	}
+	for _, name := range s.outputs {
+		C.free(unsafe.Pointer(name))
+	}
+	s.outputs = nil
+	if s.input != nil {
+		C.free(unsafe.Pointer(s.input))
+		s.input = nil
+	}
+}
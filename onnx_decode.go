@@ -0,0 +1,293 @@
+//go:build onnxruntime
+// +build onnxruntime
+
+package tfimage
+
+import (
+	"fmt"
+	"math"
+)
+
+// ONNXFaceFormat - The architecture and output format of an ONNX face detection model
+type ONNXFaceFormat uint8
+
+// ONNX face formats
+const (
+	// SCRFD models from insightface, with score, distance and keypoint outputs per stride
+	SCRFD ONNXFaceFormat = iota
+	// RetinaFace models exported from Pytorch_Retinaface, with loc, conf and landms outputs
+	RetinaFace
+)
+
+// ONNX face detection defaults
+const (
+	DefaultONNXScoreThreshold = 0.5
+	DefaultONNXNMSThreshold   = 0.4
+	defaultSCRFDSize          = 640
+)
+
+// onnxSession runs an ONNX model with a single image input
+type onnxSession interface {
+	// run feeds x to the input of the model and returns all of its outputs
+	run(x *goTensor) ([]*goTensor, error)
+	close()
+}
+
+// onnxFaceModel decodes the outputs of an ONNX face detection model to Faces
+type onnxFaceModel struct {
+	session       onnxSession
+	format        ONNXFaceFormat
+	width, height int // input size, zero runs RetinaFace at the size of the image
+	score, nms    float32
+}
+
+// DetectFaces detects the faces of img. Only params.MinSize is used, the score and
+// NMS thresholds are those of the ONNXBackend.
+func (m *onnxFaceModel) DetectFaces(img *ImageData, params MTCNNParams) ([]Face, error) {
+	if err := img.check(); err != nil {
+		return nil, err
+	}
+	var faces []Face
+	var err error
+	switch m.format {
+	case SCRFD:
+		faces, err = m.scrfd(img)
+	case RetinaFace:
+		faces, err = m.retinaFace(img)
+	default:
+		err = fmt.Errorf("unknown ONNX face format %d", m.format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("onnx face model: %v", err)
+	}
+	return onnxSelectFaces(faces, m.nms, params.MinSize), nil
+}
+
+func (m *onnxFaceModel) Close() {
+	m.session.close()
+}
+
+// scrfd letterboxes img to the top left of the input, as insightface does, and decodes the
+// scores, box distances and keypoints of each stride
+func (m *onnxFaceModel) scrfd(img *ImageData) ([]Face, error) {
+	w, h := m.width, m.height
+	if w <= 0 || h <= 0 {
+		w, h = defaultSCRFDSize, defaultSCRFDSize
+	}
+	scale := math.Min(float64(w)/float64(img.Width), float64(h)/float64(img.Height))
+	rw, rh := int(float64(img.Width)*scale), int(float64(img.Height)*scale)
+	resized, err := resizeBilinear(&goTensor{shape: []int{1, img.Height, img.Width, 3}, data: img.Pix}, rh, rw)
+	if err != nil {
+		return nil, err
+	}
+	x := newGoTensor(1, 3, h, w)
+	for i := range x.data {
+		x.data[i] = -127.5 / 128
+	}
+	for y := 0; y < rh; y++ {
+		for xx := 0; xx < rw; xx++ {
+			for c := 0; c < 3; c++ {
+				x.data[(c*h+y)*w+xx] = (resized.data[(y*rw+xx)*3+c] - 127.5) / 128
+			}
+		}
+	}
+
+	outputs, err := m.session.run(x)
+	if err != nil {
+		return nil, err
+	}
+	var strides []int
+	anchors := 1
+	switch len(outputs) {
+	case 9:
+		strides, anchors = []int{8, 16, 32}, 2
+	case 15:
+		strides = []int{8, 16, 32, 64, 128}
+	default:
+		return nil, fmt.Errorf("SCRFD model has %d outputs, expected scores, boxes and keypoints of 3 or 5 strides", len(outputs))
+	}
+
+	var faces []Face
+	s := float32(scale)
+	for i, stride := range strides {
+		scores, dist, kps := outputs[i].data, outputs[i+len(strides)].data, outputs[i+2*len(strides)].data
+		fw := w / stride
+		n := (h / stride) * fw * anchors
+		if len(scores) != n || len(dist) != n*4 || len(kps) != n*10 {
+			return nil, fmt.Errorf("SCRFD outputs of stride %d do not match an input of %dx%d", stride, w, h)
+		}
+		st := float32(stride)
+		for k := 0; k < n; k++ {
+			if scores[k] < m.score {
+				continue
+			}
+			cx, cy := float32((k/anchors)%fw*stride), float32((k/anchors)/fw*stride)
+			d := dist[k*4:]
+			f := Face{p: scores[k], box: [4]float32{
+				(cy - d[1]*st) / s, (cx - d[0]*st) / s, (cy + d[3]*st) / s, (cx + d[2]*st) / s,
+			}}
+			for j := 0; j < 5; j++ {
+				f.landmarks[j+5] = (cx + kps[k*10+j*2]*st) / s
+				f.landmarks[j] = (cy + kps[k*10+j*2+1]*st) / s
+			}
+			faces = append(faces, f)
+		}
+	}
+	return faces, nil
+}
+
+// RetinaFace anchors and box variances of Pytorch_Retinaface
+var (
+	retinaFaceSteps    = []int{8, 16, 32}
+	retinaFaceMinSizes = [][]float32{{16, 32}, {64, 128}, {256, 512}}
+	retinaFaceVariance = [2]float32{0.1, 0.2}
+	retinaFaceMean     = [3]float32{104, 117, 123} // BGR
+)
+
+// retinaFace feeds img as a BGR mean subtracted image and decodes the boxes and landmarks
+// relative to the prior boxes
+func (m *onnxFaceModel) retinaFace(img *ImageData) ([]Face, error) {
+	x := &goTensor{shape: []int{1, img.Height, img.Width, 3}, data: img.Pix}
+	if m.width > 0 && m.height > 0 {
+		var err error
+		if x, err = resizeBilinear(x, m.height, m.width); err != nil {
+			return nil, err
+		}
+	}
+	h, w := x.dim(1), x.dim(2)
+	in := newGoTensor(1, 3, h, w)
+	for i := 0; i < h*w; i++ {
+		for c := 0; c < 3; c++ {
+			in.data[c*h*w+i] = x.data[i*3+2-c] - retinaFaceMean[c]
+		}
+	}
+
+	outputs, err := m.session.run(in)
+	if err != nil {
+		return nil, err
+	}
+	var loc, conf, landms *goTensor
+	for _, out := range outputs {
+		switch out.dim(len(out.shape) - 1) {
+		case 4:
+			loc = out
+		case 2:
+			conf = out
+		case 10:
+			landms = out
+		}
+	}
+	if loc == nil || conf == nil || landms == nil {
+		return nil, fmt.Errorf("RetinaFace model outputs must be loc [N,4], conf [N,2] and landms [N,10]")
+	}
+
+	priors := retinaFacePriors(h, w)
+	n := len(priors)
+	if len(loc.data) != n*4 || len(conf.data) != n*2 || len(landms.data) != n*10 {
+		return nil, fmt.Errorf("RetinaFace outputs do not match the %d priors of an input of %dx%d", n, w, h)
+	}
+	v0, v1 := retinaFaceVariance[0], retinaFaceVariance[1]
+	iw, ih := float32(img.Width), float32(img.Height)
+	var faces []Face
+	for i, p := range priors {
+		score := retinaFaceScore(conf.data[i*2], conf.data[i*2+1])
+		if score < m.score {
+			continue
+		}
+		l := loc.data[i*4:]
+		cx, cy := p[0]+l[0]*v0*p[2], p[1]+l[1]*v0*p[3]
+		bw, bh := p[2]*float32(math.Exp(float64(l[2]*v1))), p[3]*float32(math.Exp(float64(l[3]*v1)))
+		f := Face{p: score, box: [4]float32{
+			(cy - bh/2) * ih, (cx - bw/2) * iw, (cy + bh/2) * ih, (cx + bw/2) * iw,
+		}}
+		lm := landms.data[i*10:]
+		for j := 0; j < 5; j++ {
+			f.landmarks[j+5] = (p[0] + lm[j*2]*v0*p[2]) * iw
+			f.landmarks[j] = (p[1] + lm[j*2+1]*v0*p[3]) * ih
+		}
+		faces = append(faces, f)
+	}
+	return faces, nil
+}
+
+// retinaFacePriors returns the prior boxes [cx, cy, w, h] of an input of h by w, normalized to [0,1]
+func retinaFacePriors(h, w int) (priors [][4]float32) {
+	for k, step := range retinaFaceSteps {
+		fh, fw := (h+step-1)/step, (w+step-1)/step
+		for i := 0; i < fh; i++ {
+			for j := 0; j < fw; j++ {
+				for _, size := range retinaFaceMinSizes[k] {
+					priors = append(priors, [4]float32{
+						(float32(j) + 0.5) * float32(step) / float32(w),
+						(float32(i) + 0.5) * float32(step) / float32(h),
+						size / float32(w),
+						size / float32(h),
+					})
+				}
+			}
+		}
+	}
+	return priors
+}
+
+// retinaFaceScore returns the face probability of a conf pair, applying a
+// softmax when the model exports logits
+func retinaFaceScore(bg, face float32) float32 {
+	if bg >= 0 && face >= 0 && math.Abs(float64(bg+face-1)) < 1e-3 {
+		return face
+	}
+	return 1 / (1 + float32(math.Exp(float64(bg-face))))
+}
+
+// onnxSelectFaces removes faces smaller than minSize and overlapping faces
+func onnxSelectFaces(faces []Face, iou float32, minSize float32) []Face {
+	boxes := make([][4]float32, 0, len(faces))
+	scores := make([]float32, 0, len(faces))
+	kept := faces[:0]
+	for _, f := range faces {
+		if f.box[2]-f.box[0] < minSize || f.box[3]-f.box[1] < minSize {
+			continue
+		}
+		kept = append(kept, f)
+		boxes = append(boxes, f.box)
+		scores = append(scores, f.p)
+	}
+	idx := nonMaxSuppression(boxes, scores, iou, len(boxes))
+	res := make([]Face, len(idx))
+	for i, j := range idx {
+		res[i] = kept[j]
+	}
+	return res
+}
+
+// onnxAestheticsModel is a quality model of the ONNXBackend. The image is fed with
+// 0-255 values and the first output holds the probabilities of the scores 1 to 10.
+type onnxAestheticsModel struct {
+	session onnxSession
+	nchw    bool
+}
+
+func (m *onnxAestheticsModel) Scores(img *ImageData) ([]float32, error) {
+	if err := img.check(); err != nil {
+		return nil, err
+	}
+	x := &goTensor{shape: []int{1, img.Height, img.Width, 3}, data: img.Pix}
+	if m.nchw {
+		x = newGoTensor(1, 3, img.Height, img.Width)
+		n := img.Height * img.Width
+		for i := 0; i < n; i++ {
+			for c := 0; c < 3; c++ {
+				x.data[c*n+i] = img.Pix[i*3+c]
+			}
+		}
+	}
+	outputs, err := m.session.run(x)
+	if err != nil {
+		return nil, fmt.Errorf("aesthetics model: %v", err)
+	}
+	return outputs[0].data, nil
+}
+
+func (m *onnxAestheticsModel) Close() {
+	m.session.close()
+}
@@ -0,0 +1,62 @@
+//go:build cgo && onnxruntime
+// +build cgo,onnxruntime
+
+package tfimage
+
+import (
+	"os"
+	"testing"
+)
+
+// scrfdMinIoU is the minimum IoU of the SCRFD face with the MTCNN face of the
+// same image. The detectors draw boxes differently, so the match is loose.
+const scrfdMinIoU = 0.5
+
+// TestONNXSCRFDSmoke loads the SCRFD model at $TFIMAGE_SCRFD_MODEL, such as
+// scrfd_500m_bnkps.onnx from insightface, and detects the face of a portrait.
+func TestONNXSCRFDSmoke(t *testing.T) {
+	path := os.Getenv("TFIMAGE_SCRFD_MODEL")
+	if path == "" {
+		t.Skip("TFIMAGE_SCRFD_MODEL is not set")
+	}
+	det, err := NewFaceDetector(path, FaceDetectorOptions{ModelOptions: ModelOptions{
+		Backend: ONNXBackend{Face: SCRFD},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer det.Close()
+	ref, err := NewFaceDetectorFromBytes(readModel(t, "mtcnn_1.14.pb"), FaceDetectorOptions{
+		ModelOptions: ModelOptions{Backend: GoBackend},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ref.Close()
+
+	img := testImages(t)["grace_hopper"]
+	got, err := det.DetectImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ref.DetectImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Len() != 1 || want.Len() != 1 {
+		t.Fatalf("SCRFD found %d faces and MTCNN %d, want 1", got.Len(), want.Len())
+	}
+	f := got.results[0]
+	if f.p < DefaultONNXScoreThreshold {
+		t.Errorf("probability %.3f is under the score threshold", f.p)
+	}
+	if iou := faceIoU(f, want.results[0]); iou < scrfdMinIoU {
+		t.Errorf("box %v has an IoU of %.3f with the MTCNN box %v, want at least %.2f", f.box, iou, want.results[0].box, scrfdMinIoU)
+	}
+	for i := 0; i < 5; i++ {
+		y, x := f.landmarks[i], f.landmarks[i+5]
+		if y < f.box[0] || y > f.box[2] || x < f.box[1] || x > f.box[3] {
+			t.Errorf("landmark %d (%.1f, %.1f) is outside of the box %v", i, x, y, f.box)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package tfimage
+
+// ImageInput is the logical name of a Model's image input.
+// A Model's Preprocess is applied to the tensor fed to this input.
+const ImageInput = "image"
+
+// ModelOptions - Options used to load a Model
+type ModelOptions struct {
+	// Backend runs the model, nil uses the default Backend
+	Backend    Backend
+	Session    SessionOptions
+	SavedModel SavedModelOptions // used by the SavedModel constructors
+	Warmup     WarmupOptions
+
+	// Signature replaces the operation names of the default signature of the model,
+	// keyed by logical name. The Registry constructors set it from the model entry.
+	// It is used by the TensorflowBackend and the NIMA models of the GoBackend.
+	Signature ModelSignature
+}
+
+// backend returns the Backend of the options, or the default Backend
+func (o ModelOptions) backend() Backend {
+	if o.Backend != nil {
+		return o.Backend
+	}
+	return defaultBackend()
+}
+
+// SavedModel defaults
+const (
+	DefaultSavedModelTag       = "serve"
+	DefaultSavedModelSignature = "serving_default"
+)
+
+// SavedModelOptions - Selects the graph and the signature of a SavedModel
+type SavedModelOptions struct {
+	// Tags identify the MetaGraph to load, defaults to DefaultSavedModelTag
+	Tags []string
+	// SignatureKey selects the SignatureDef, defaults to DefaultSavedModelSignature
+	SignatureKey string
+}
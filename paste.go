@@ -0,0 +1,141 @@
+package tfimage
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// MaskShape - The shape of the mask that blends a face crop into its source image
+type MaskShape uint8
+
+// Mask shapes
+const (
+	// EllipseMask is the ellipse inscribed in the box of the face
+	EllipseMask MaskShape = iota
+	// LandmarkMask is an ellipse around the landmarks of the face, which
+	// covers the eyes, nose and mouth more tightly than the box
+	LandmarkMask
+)
+
+// landmarkMaskScale is the size of a LandmarkMask relative to the spread of the landmarks
+const landmarkMaskScale = 1.8
+
+// PasteOptions - Options of PasteBack
+type PasteOptions struct {
+	// Align is the template the face crop was made with. A zero Width and
+	// Height uses the size of the crop with the default template.
+	Align AlignOptions
+	// Kernel resamples the crop, defaults to draw.BiLinear
+	Kernel draw.Interpolator
+	Mask   MaskShape
+	// Feather is the width of the soft edge of the mask, as a fraction of its radius.
+	// Defaults to 0.2, and a negative value gives a hard edge.
+	Feather float64
+}
+
+// PasteBack blends faceCrop, an aligned crop of face in src that may have been
+// processed, back into src. The crop is warped with the inverse of the alignment
+// matrix and blended with a feathered mask. face is in the coordinates of src, as
+// for AlignImage, and the returned image is src moved to the origin. src is not modified.
+func PasteBack(src image.Image, faceCrop image.Image, face Face, opts PasteOptions) (*image.RGBA, error) {
+	if opts.Align.Width == 0 && opts.Align.Height == 0 {
+		opts.Align = DefaultAlignOptions(faceCrop.Bounds().Dx(), faceCrop.Bounds().Dy())
+	}
+	if opts.Kernel == nil {
+		opts.Kernel = draw.BiLinear
+	}
+	feather := opts.Feather
+	if feather == 0 {
+		feather = 0.2
+	}
+	feather = math.Max(math.Min(feather, 1), 0)
+
+	m := face.AlignMatrix(opts.Align)
+	inv, err := m.Invert()
+	if err != nil {
+		return nil, err
+	}
+
+	sb := src.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, sb.Dx(), sb.Dy()))
+	draw.Draw(out, out.Rect, src, sb.Min, draw.Src)
+
+	// The crop is warped into a layer over the region it covers in src
+	crop := image.Rect(0, 0, opts.Align.Width, opts.Align.Height)
+	region := mappedBounds(inv, crop).Intersect(sb)
+	if region.Empty() {
+		return out, nil
+	}
+	layer := image.NewRGBA(region)
+	toCrop := Translate(float64(-faceCrop.Bounds().Min.X), float64(-faceCrop.Bounds().Min.Y)).Multiply(inv)
+	opts.Kernel.Transform(layer, toCrop.ToAffineMatrix(), faceCrop, faceCrop.Bounds(), draw.Src, nil)
+
+	// Mask ellipse in crop coordinates
+	cf := face.transform(m)
+	var cx, cy, rx, ry float64
+	switch opts.Mask {
+	case LandmarkMask:
+		for i := 0; i < 5; i++ {
+			cx, cy = cx+float64(cf.landmarks[i+5])/5, cy+float64(cf.landmarks[i])/5
+		}
+		for i := 0; i < 5; i++ {
+			rx = math.Max(rx, math.Abs(float64(cf.landmarks[i+5])-cx))
+			ry = math.Max(ry, math.Abs(float64(cf.landmarks[i])-cy))
+		}
+		rx, ry = rx*landmarkMaskScale, ry*landmarkMaskScale
+	default:
+		cx, cy = float64(cf.box[1]+cf.box[3])/2, float64(cf.box[0]+cf.box[2])/2
+		rx, ry = float64(cf.box[3]-cf.box[1])/2, float64(cf.box[2]-cf.box[0])/2
+	}
+	if rx <= 0 || ry <= 0 {
+		return out, nil
+	}
+
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			px, py := m.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+			if px < 0 || py < 0 || px >= float64(crop.Max.X) || py >= float64(crop.Max.Y) {
+				continue
+			}
+			a := maskAlpha(math.Hypot((px-cx)/rx, (py-cy)/ry), feather)
+			if a == 0 {
+				continue
+			}
+			i, j := out.PixOffset(x-sb.Min.X, y-sb.Min.Y), layer.PixOffset(x, y)
+			// layer is premultiplied, so the crop alpha is part of its color values
+			la := a * float64(layer.Pix[j+3]) / 255
+			for c := 0; c < 4; c++ {
+				v := float64(out.Pix[i+c])*(1-la) + float64(layer.Pix[j+c])*a
+				out.Pix[i+c] = uint8(math.Min(math.Round(v), 255))
+			}
+		}
+	}
+	return out, nil
+}
+
+// maskAlpha returns the opacity of the mask at the normalized radius r, with a
+// smooth edge of the width feather inside the radius
+func maskAlpha(r, feather float64) float64 {
+	switch {
+	case r >= 1:
+		return 0
+	case feather == 0 || r <= 1-feather:
+		return 1
+	}
+	t := (1 - r) / feather
+	return t * t * (3 - 2*t)
+}
+
+// mappedBounds returns the pixel bounds of the rectangle r mapped through m
+func mappedBounds(m Matrix, r image.Rectangle) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{r.Min, {r.Max.X, r.Min.Y}, {r.Min.X, r.Max.Y}, r.Max} {
+		x, y := m.TransformPoint(float64(p.X), float64(p.Y))
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
@@ -0,0 +1,172 @@
+package tfimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+// testPasteFace is an upright 100x120 face
+var testPasteFace = NewFace(0.99, [4]float32{100, 200, 220, 300}, [10]float32{150, 150, 190, 190, 170, 230, 270, 235, 265, 250})
+
+// uniformImage returns an image of bounds r filled with c
+func uniformImage(r image.Rectangle, c color.Color) *image.RGBA {
+	img := image.NewRGBA(r)
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// pasteMaskRadius returns the normalized radius of the EllipseMask at the center of
+// pixel (x,y) of the source, and whether the pixel maps inside of the crop
+func pasteMaskRadius(f Face, opts AlignOptions, x, y int) (float64, bool) {
+	m := f.AlignMatrix(opts)
+	cf := f.transform(m)
+	px, py := m.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+	// one pixel away from the edges of the crop, where the kernel blends in transparency
+	if px < 1 || py < 1 || px >= float64(opts.Width-1) || py >= float64(opts.Height-1) {
+		return 0, false
+	}
+	cx, cy := float64(cf.box[1]+cf.box[3])/2, float64(cf.box[0]+cf.box[2])/2
+	rx, ry := float64(cf.box[3]-cf.box[1])/2, float64(cf.box[2]-cf.box[0])/2
+	return math.Hypot((px-cx)/rx, (py-cy)/ry), true
+}
+
+func TestPasteBackOpaque(t *testing.T) {
+	gray, red := color.RGBA{90, 90, 90, 255}, color.RGBA{255, 0, 0, 255}
+	src := uniformImage(image.Rect(0, 0, 400, 300), gray)
+	opts := DefaultAlignOptions(112, 112)
+	crop := uniformImage(image.Rect(0, 0, 112, 112), red)
+
+	out, err := PasteBack(src, crop, testPasteFace, PasteOptions{Align: opts, Feather: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Bounds() != src.Bounds() {
+		t.Fatalf("bounds %v, want %v", out.Bounds(), src.Bounds())
+	}
+	if c := src.RGBAAt(250, 160); c != gray {
+		t.Fatalf("src modified: %v", c)
+	}
+	inside, outside := 0, 0
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			want := gray
+			r, ok := pasteMaskRadius(testPasteFace, opts, x, y)
+			if ok && r < 1 {
+				want = red
+				inside++
+			} else if !ok && (x < 190 || x > 310 || y < 90 || y > 230) {
+				outside++ // far from the crop
+			} else {
+				continue
+			}
+			if c := out.RGBAAt(x, y); c != want {
+				t.Fatalf("pixel (%d,%d) at radius %.2f: %v, want %v", x, y, r, c, want)
+			}
+		}
+	}
+	if inside == 0 || outside == 0 {
+		t.Fatalf("%d pixels inside of the mask and %d outside", inside, outside)
+	}
+}
+
+func TestPasteBackFeather(t *testing.T) {
+	if a := maskAlpha(0.9, 0.2); math.Abs(a-0.5) > 1e-12 {
+		t.Errorf("alpha %v at the middle of the feather, want 0.5", a)
+	}
+	for _, r := range []float64{0, 0.5, 0.8} {
+		if a := maskAlpha(r, 0.2); a != 1 {
+			t.Errorf("alpha %v at radius %v, inside of the feather", a, r)
+		}
+	}
+	if a := maskAlpha(1, 0.2); a != 0 {
+		t.Errorf("alpha %v at radius 1", a)
+	}
+
+	// a half transparent crop, premultiplied to {100, 0, 0, 128}
+	src := uniformImage(image.Rect(0, 0, 400, 300), color.RGBA{0, 0, 200, 255})
+	opts := DefaultAlignOptions(112, 112)
+	crop := uniformImage(image.Rect(0, 0, 112, 112), color.NRGBA{200, 0, 0, 128})
+	cp := crop.RGBAAt(0, 0)
+	out, err := PasteBack(src, crop, testPasteFace, PasteOptions{Align: opts, Kernel: draw.NearestNeighbor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feathered := 0
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			r, ok := pasteMaskRadius(testPasteFace, opts, x, y)
+			if !ok || r >= 1 {
+				continue
+			}
+			a := maskAlpha(r, 0.2)
+			if a < 1 {
+				feathered++
+			}
+			// src over by the crop color scaled by the mask
+			la := a * float64(cp.A) / 255
+			want := color.RGBA{
+				R: uint8(math.Round(float64(cp.R) * a)),
+				B: uint8(math.Round(200*(1-la) + float64(cp.B)*a)),
+				A: uint8(math.Round(255*(1-la) + float64(cp.A)*a)),
+			}
+			if c := out.RGBAAt(x, y); absDiff(c.R, want.R) > 1 || c.G != 0 || absDiff(c.B, want.B) > 1 || absDiff(c.A, want.A) > 1 {
+				t.Fatalf("pixel (%d,%d) at radius %.3f: %v, want %v", x, y, r, c, want)
+			}
+		}
+	}
+	if feathered == 0 {
+		t.Fatal("no pixels in the feather")
+	}
+}
+
+func TestPasteBackSubImage(t *testing.T) {
+	// a source with varied content, and the same source as a sub-image
+	full := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for i := range full.Pix {
+		full.Pix[i] = uint8(i*13) | 0x80
+	}
+	rect := image.Rect(150, 60, 380, 280)
+	sub := full.SubImage(rect)
+
+	opts := DefaultAlignOptions(112, 112)
+	// the crop is made from the sub-image, in the coordinates of the source
+	crop := testPasteFace.AlignImage(sub, draw.BiLinear, opts)
+	want, err := PasteBack(full, crop, testPasteFace, PasteOptions{Align: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := PasteBack(sub, crop, testPasteFace, PasteOptions{Align: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Bounds() != image.Rect(0, 0, rect.Dx(), rect.Dy()) {
+		t.Fatalf("bounds %v, want the size of %v", got.Bounds(), rect)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if c, w := got.RGBAAt(x-rect.Min.X, y-rect.Min.Y), want.RGBAAt(x, y); c != w {
+				t.Fatalf("pixel (%d,%d) of the sub-image %v, want %v", x, y, c, w)
+			}
+		}
+	}
+	// the face was pasted, and the pixels around it are the source
+	changed := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if got.RGBAAt(x-rect.Min.X, y-rect.Min.Y) != full.RGBAAt(x, y) {
+				changed++
+			}
+		}
+	}
+	if changed == 0 {
+		t.Error("no pixels pasted")
+	}
+	if c := got.RGBAAt(0, 0); c != full.RGBAAt(rect.Min.X, rect.Min.Y) {
+		t.Errorf("corner %v, want %v", c, full.RGBAAt(rect.Min.X, rect.Min.Y))
+	}
+}
@@ -0,0 +1,192 @@
+package tfimage
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// ChannelOrder - order of the color channels of an image tensor
+type ChannelOrder uint8
+
+// Channel orders
+const (
+	RGB ChannelOrder = iota
+	BGR
+)
+
+// Layout - memory layout of an image tensor
+type Layout uint8
+
+// Layouts
+const (
+	NHWC Layout = iota
+	NCHW
+)
+
+// ResizeMethod - interpolation used to resize an image tensor
+type ResizeMethod uint8
+
+// Resize methods
+const (
+	ResizeBilinear ResizeMethod = iota
+	ResizeNearest
+	ResizeBicubic
+	ResizeArea
+)
+
+var (
+	channelOrderNames = []string{RGB: "rgb", BGR: "bgr"}
+	layoutNames       = []string{NHWC: "nhwc", NCHW: "nchw"}
+	resizeMethodNames = []string{ResizeBilinear: "bilinear", ResizeNearest: "nearest", ResizeBicubic: "bicubic", ResizeArea: "area"}
+)
+
+func (o ChannelOrder) String() string { return enumName(channelOrderNames, int(o)) }
+func (l Layout) String() string       { return enumName(layoutNames, int(l)) }
+func (m ResizeMethod) String() string { return enumName(resizeMethodNames, int(m)) }
+
+// MarshalText implements encoding.TextMarshaler
+func (o ChannelOrder) MarshalText() ([]byte, error) { return []byte(o.String()), nil }
+
+// MarshalText implements encoding.TextMarshaler
+func (l Layout) MarshalText() ([]byte, error) { return []byte(l.String()), nil }
+
+// MarshalText implements encoding.TextMarshaler
+func (m ResizeMethod) MarshalText() ([]byte, error) { return []byte(m.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (o *ChannelOrder) UnmarshalText(text []byte) error {
+	v, err := parseEnum("channel order", channelOrderNames, string(text))
+	*o = ChannelOrder(v)
+	return err
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (l *Layout) UnmarshalText(text []byte) error {
+	v, err := parseEnum("layout", layoutNames, string(text))
+	*l = Layout(v)
+	return err
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *ResizeMethod) UnmarshalText(text []byte) error {
+	v, err := parseEnum("resize method", resizeMethodNames, string(text))
+	*m = ResizeMethod(v)
+	return err
+}
+
+func enumName(names []string, v int) string {
+	if v < len(names) {
+		return names[v]
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+func parseEnum(kind string, names []string, text string) (int, error) {
+	for i, name := range names {
+		if name == text {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q", kind, text)
+}
+
+// Preprocess - Specification of the image normalization applied in-graph
+// before an image tensor is fed to a model.
+//
+// Values are computed as (pixel*Scale - Mean) / Std, with Mean and Std given
+// in the output channel order. For example [0,1] scaling is Scale: 1.0 / 255,
+// and [-1,1] scaling is Scale: 1.0 / 127.5 with a Mean of 1.
+// The zero value leaves the image tensor unchanged.
+type Preprocess struct {
+	Order  ChannelOrder
+	Layout Layout
+	Mean   [3]float32
+	Std    [3]float32 // zero values are treated as 1
+	Scale  float32    // zero is treated as 1
+
+	// Width and Height are the target size. Zero keeps the size of the input.
+	Width, Height int
+	Resize        ResizeMethod
+
+	// Letterbox keeps the aspect ratio of the input and pads it to the
+	// target size with LetterboxColor.
+	Letterbox      bool
+	LetterboxColor color.RGBA
+}
+
+// Denormalize returns the Denormalize that reverses the normalization of the Preprocess
+func (p Preprocess) Denormalize() Denormalize {
+	return Denormalize{Mean: p.Mean, Std: p.Std, Scale: p.Scale, BGR: p.Order == BGR, Layout: p.Layout}
+}
+
+func (p Preprocess) resizes() bool {
+	return p.Width > 0 && p.Height > 0
+}
+
+// geometry returns the size of the resized image and the padding
+// used to fit an image of width w and height h to the target size.
+func (p Preprocess) geometry(w, h int) (rw, rh, left, top int) {
+	if !p.resizes() {
+		return w, h, 0, 0
+	}
+	if !p.Letterbox {
+		return p.Width, p.Height, 0, 0
+	}
+	s := math.Min(float64(p.Width)/float64(w), float64(p.Height)/float64(h))
+	rw = int(math.Round(float64(w) * s))
+	rh = int(math.Round(float64(h) * s))
+	return rw, rh, (p.Width - rw) / 2, (p.Height - rh) / 2
+}
+
+// inverse returns the Matrix that maps points in the preprocessed tensor
+// back to points in an input image of width w and height h.
+func (p Preprocess) inverse(w, h int) Matrix {
+	rw, rh, left, top := p.geometry(w, h)
+	sx, sy := float64(w)/float64(rw), float64(h)/float64(rh)
+	return Translate(-float64(left), -float64(top)).Multiply(Scale(sx, sy))
+}
+
+func (p Preprocess) std() (std [3]float32) {
+	for i, v := range p.Std {
+		std[i] = v
+		if v == 0 {
+			std[i] = 1
+		}
+	}
+	return std
+}
+
+// Denormalize describes how tensor values are mapped back to 0-255 pixel values.
+// Each pixel is computed as (value*Std + Mean) / Scale and clamped, with Mean and Std
+// given in the channel order of the tensor.
+// The zero value maps raw 0-255 tensors unchanged.
+type Denormalize struct {
+	Mean  [3]float32
+	Std   [3]float32 // zero values are treated as 1
+	Scale float32    // zero is treated as 1
+	BGR   bool       // tensor channels are in BGR order
+
+	// Layout of the tensor, NCHW tensors are transposed to images
+	Layout Layout
+}
+
+func (d Denormalize) pixel(v float32, c int) uint8 {
+	if c < 3 {
+		if d.Std[c] != 0 {
+			v *= d.Std[c]
+		}
+		v += d.Mean[c]
+	}
+	if d.Scale != 0 {
+		v /= d.Scale
+	}
+	v = float32(math.Round(float64(v)))
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
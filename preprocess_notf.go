@@ -0,0 +1,18 @@
+//go:build !cgo || notensorflow
+// +build !cgo notensorflow
+
+package tfimage
+
+import "fmt"
+
+// SetPreprocess attaches a Preprocess that is applied to image tensors before detection.
+// It requires the TensorflowBackend, which is not available in this build.
+func (det *FaceDetector) SetPreprocess(p Preprocess) error {
+	return fmt.Errorf("preprocess is not supported by the backend of the face detector")
+}
+
+// SetPreprocess attaches a Preprocess that is applied to image tensors before evaluation.
+// It requires the TensorflowBackend, which is not available in this build.
+func (eval *AestheticsEvaluator) SetPreprocess(p Preprocess) error {
+	return fmt.Errorf("preprocess is not supported by the backend of the aesthetics evaluator")
+}
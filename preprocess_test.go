@@ -0,0 +1,57 @@
+package tfimage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPreprocessLetterboxGeometry(t *testing.T) {
+	tests := []struct {
+		name              string
+		p                 Preprocess
+		w, h              int
+		rw, rh, left, top int
+	}{
+		{"landscape", Preprocess{Width: 224, Height: 224, Letterbox: true}, 640, 480, 224, 168, 0, 28},
+		{"portrait", Preprocess{Width: 224, Height: 224, Letterbox: true}, 300, 600, 112, 224, 56, 0},
+		{"wide target", Preprocess{Width: 320, Height: 160, Letterbox: true}, 100, 100, 160, 160, 80, 0},
+		{"stretch", Preprocess{Width: 224, Height: 224}, 640, 480, 224, 224, 0, 0},
+		{"no resize", Preprocess{}, 640, 480, 640, 480, 0, 0},
+	}
+	for _, tt := range tests {
+		rw, rh, left, top := tt.p.geometry(tt.w, tt.h)
+		if rw != tt.rw || rh != tt.rh || left != tt.left || top != tt.top {
+			t.Errorf("%s: geometry(%d, %d) = %d, %d, %d, %d, want %d, %d, %d, %d",
+				tt.name, tt.w, tt.h, rw, rh, left, top, tt.rw, tt.rh, tt.left, tt.top)
+		}
+	}
+}
+
+func TestPreprocessLetterboxInverse(t *testing.T) {
+	p := Preprocess{Width: 224, Height: 224, Letterbox: true}
+	const w, h = 640, 480
+	rw, rh, left, top := p.geometry(w, h)
+	inv := p.inverse(w, h)
+
+	// corners of the image content inside the padded tensor
+	corners := [][4]float64{
+		{float64(left), float64(top), 0, 0},
+		{float64(left + rw), float64(top), w, 0},
+		{float64(left), float64(top + rh), 0, h},
+		{float64(left + rw), float64(top + rh), w, h},
+	}
+	for _, c := range corners {
+		x, y := inv.TransformPoint(c[0], c[1])
+		if math.Abs(x-c[2]) > 1e-9 || math.Abs(y-c[3]) > 1e-9 {
+			t.Errorf("inverse maps (%v, %v) to (%v, %v), want (%v, %v)", c[0], c[1], x, y, c[2], c[3])
+		}
+	}
+
+	// the padding maps outside of the source image
+	if _, y := inv.TransformPoint(0, 0); y >= 0 {
+		t.Errorf("top padding maps to y %v, want < 0", y)
+	}
+	if _, y := inv.TransformPoint(0, 223); y <= h {
+		t.Errorf("bottom padding maps to y %v, want > %d", y, h)
+	}
+}
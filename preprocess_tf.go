@@ -0,0 +1,136 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"errors"
+	"fmt"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// preprocessor is a compiled Preprocess graph
+type preprocessor struct {
+	spec    Preprocess
+	graph   *tf.Graph
+	session *tf.Session
+
+	input, size, paddings, output tf.Output
+}
+
+func newPreprocessor(p Preprocess, options SessionOptions) (*preprocessor, error) {
+	if p.Width < 0 || p.Height < 0 || (p.Width == 0) != (p.Height == 0) {
+		return nil, fmt.Errorf("invalid preprocess target size %dx%d", p.Width, p.Height)
+	}
+	pp := &preprocessor{spec: p}
+
+	s := op.NewScope()
+	pp.input = op.Placeholder(s.SubScope("input"), tf.Float, op.PlaceholderShape(tf.MakeShape(1, -1, -1, 3)))
+	out := pp.input
+
+	if p.resizes() {
+		pp.size = op.Placeholder(s.SubScope("size"), tf.Int32, op.PlaceholderShape(tf.MakeShape(2)))
+		switch p.Resize {
+		case ResizeBilinear:
+			out = op.ResizeBilinear(s, out, pp.size)
+		case ResizeNearest:
+			out = op.ResizeNearestNeighbor(s, out, pp.size)
+		case ResizeBicubic:
+			out = op.ResizeBicubic(s, out, pp.size)
+		case ResizeArea:
+			out = op.ResizeArea(s, out, pp.size)
+		default:
+			return nil, fmt.Errorf("unknown resize method %d", p.Resize)
+		}
+
+		if p.Letterbox {
+			c := p.LetterboxColor
+			fill := op.Const(s.SubScope("letterbox_color"), []float32{float32(c.R), float32(c.G), float32(c.B)})
+			pp.paddings = op.Placeholder(s.SubScope("paddings"), tf.Int32, op.PlaceholderShape(tf.MakeShape(4, 2)))
+			out = op.Add(s, op.Pad(s, op.Sub(s, out, fill), pp.paddings), fill)
+		}
+	}
+
+	if p.Order == BGR {
+		out = op.ReverseV2(s, out, op.Const(s.SubScope("channel_axis"), []int32{3}))
+	}
+
+	if p.Scale != 0 && p.Scale != 1 {
+		out = op.Mul(s, out, op.Const(s.SubScope("scale"), p.Scale))
+	}
+	if p.Mean != [3]float32{} {
+		out = op.Sub(s, out, op.Const(s.SubScope("mean"), p.Mean[:]))
+	}
+	if std := p.std(); std != [3]float32{1, 1, 1} {
+		out = op.RealDiv(s, out, op.Const(s.SubScope("std"), std[:]))
+	}
+
+	if p.Layout == NCHW {
+		out = op.Transpose(s, out, op.Const(s.SubScope("perm"), []int32{0, 3, 1, 2}))
+	}
+	pp.output = out
+
+	var err error
+	if pp.graph, err = s.Finalize(); err != nil {
+		return nil, err
+	}
+	if pp.session, err = tf.NewSession(pp.graph, options.tfOptions()); err != nil {
+		return nil, err
+	}
+	return pp, nil
+}
+
+// Apply runs the preprocess graph on a [1,H,W,3] float32 image tensor.
+// It returns the resulting tensor and the Matrix that maps points in the
+// resulting tensor back to points in the input image.
+func (pp *preprocessor) Apply(tensor *tf.Tensor) (*tf.Tensor, Matrix, error) {
+	shape := tensor.Shape()
+	if len(shape) != 4 || shape[0] != 1 || shape[3] != 3 {
+		return nil, Matrix{}, fmt.Errorf("preprocess: tensor shape %v, expected [1,H,W,3]", shape)
+	}
+	if tensor.DataType() != tf.Float {
+		return nil, Matrix{}, fmt.Errorf("preprocess: tensor type %s, expected float32", dataTypeName(tensor.DataType()))
+	}
+	w, h := int(shape[2]), int(shape[1])
+
+	feeds := map[tf.Output]*tf.Tensor{pp.input: tensor}
+	inverse := NewMatrix()
+	if pp.spec.resizes() {
+		rw, rh, left, top := pp.spec.geometry(w, h)
+		if rw <= 0 || rh <= 0 {
+			return nil, Matrix{}, errors.New("preprocess: image is too small for the target size")
+		}
+		size, err := tf.NewTensor([]int32{int32(rh), int32(rw)})
+		if err != nil {
+			return nil, Matrix{}, err
+		}
+		feeds[pp.size] = size
+
+		if pp.spec.Letterbox {
+			right, bottom := pp.spec.Width-rw-left, pp.spec.Height-rh-top
+			paddings, err := tf.NewTensor([][]int32{{0, 0}, {int32(top), int32(bottom)}, {int32(left), int32(right)}, {0, 0}})
+			if err != nil {
+				return nil, Matrix{}, err
+			}
+			feeds[pp.paddings] = paddings
+		}
+		inverse = pp.spec.inverse(w, h)
+	}
+
+	out, err := pp.session.Run(feeds, []tf.Output{pp.output}, nil)
+	if err != nil {
+		return nil, Matrix{}, err
+	}
+	return out[0], inverse, nil
+}
+
+// Close closes the preprocessor's Session
+func (pp *preprocessor) Close() {
+	if pp.session != nil {
+		pp.session.Close()
+		pp.graph = nil
+		pp.session = nil
+	}
+}
@@ -0,0 +1,59 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestPreprocessorLetterbox(t *testing.T) {
+	fill := color.RGBA{200, 100, 50, 0xff}
+	p := Preprocess{Width: 8, Height: 8, Resize: ResizeNearest, Letterbox: true, LetterboxColor: fill}
+	pp, err := newPreprocessor(p, SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pp.Close()
+
+	src := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	content := color.RGBA{10, 20, 30, 0xff}
+	draw.Draw(src, src.Bounds(), image.NewUniform(content), image.Point{}, draw.Src)
+	tensor, err := NewImageData(src).tensor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, inverse, err := pp.Apply(tensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := TensorToImage(out, p.Denormalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("bounds %v, want 8x8", img.Bounds())
+	}
+	// the 8x4 image is centered with 2 rows of padding above and below
+	for y := 0; y < 8; y++ {
+		want := fill
+		if y >= 2 && y < 6 {
+			want = content
+		}
+		for x := 0; x < 8; x++ {
+			if got := img.At(x, y); got != want {
+				t.Errorf("pixel (%d,%d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	for _, c := range [][4]float64{{0, 2, 0, 0}, {8, 6, 8, 4}} {
+		if x, y := inverse.TransformPoint(c[0], c[1]); x != c[2] || y != c[3] {
+			t.Errorf("inverse maps (%v, %v) to (%v, %v), want (%v, %v)", c[0], c[1], x, y, c[2], c[3])
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package tfimage
+
+// Minimal protocol buffer wire format encoding and decoding, used for the
+// tensorflow configuration and SavedModel messages without a protobuf dependency.
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("proto: truncated message")
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendInt appends an int32, int64 or enum field. Negative values are sign extended.
+func appendInt(b []byte, field int, v int64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return appendVarint(b, uint64(v))
+}
+
+func appendBool(b []byte, field int, v bool) []byte {
+	var i int64
+	if v {
+		i = 1
+	}
+	return appendInt(b, field, i)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// protoField is a field of an encoded protocol buffer message.
+// Varint holds the value of varint fields and Bytes the value of length delimited fields.
+type protoField struct {
+	Num    int
+	Wire   int
+	Varint uint64
+	Bytes  []byte
+}
+
+// decodeProto calls fn for each field of the encoded message b
+func decodeProto(b []byte, fn func(f protoField) error) error {
+	for len(b) > 0 {
+		key, n := readVarint(b)
+		if n == 0 {
+			return errProtoTruncated
+		}
+		b = b[n:]
+		f := protoField{Num: int(key >> 3), Wire: int(key & 7)}
+		switch f.Wire {
+		case wireVarint:
+			if f.Varint, n = readVarint(b); n == 0 {
+				return errProtoTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errProtoTruncated
+			}
+			f.Bytes, b = b[:8], b[8:]
+		case wireBytes:
+			l, n := readVarint(b)
+			if n == 0 || uint64(len(b)-n) < l {
+				return errProtoTruncated
+			}
+			f.Bytes, b = b[n:n+int(l)], b[n+int(l):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errProtoTruncated
+			}
+			f.Bytes, b = b[:4], b[4:]
+		default:
+			return fmt.Errorf("proto: unsupported wire type %d", f.Wire)
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVarint decodes a varint, returning the value and the number of bytes read, or 0 bytes if b is truncated.
+func readVarint(b []byte) (v uint64, n int) {
+	for i, c := range b {
+		if i == 10 {
+			return 0, 0
+		}
+		v |= uint64(c&0x7f) << (7 * uint(i))
+		if c < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// map entry field numbers
+const (
+	mapKey   = 1
+	mapValue = 2
+)
+
+// decodeMapEntry decodes a map<string, message> entry
+func decodeMapEntry(b []byte) (key string, value []byte, err error) {
+	err = decodeProto(b, func(f protoField) error {
+		switch f.Num {
+		case mapKey:
+			key = string(f.Bytes)
+		case mapValue:
+			value = f.Bytes
+		}
+		return nil
+	})
+	return key, value, err
+}
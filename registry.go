@@ -0,0 +1,269 @@
+package tfimage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModelKind - The kind of model of a registry entry
+type ModelKind string
+
+// Model kinds
+const (
+	MTCNNModel ModelKind = "mtcnn"
+	NIMAModel  ModelKind = "nima"
+)
+
+// ModelEntry - A model of a Registry
+type ModelEntry struct {
+	Name string `json:"name"`
+	// Path is the path of the frozen GraphDef, relative to the manifest
+	Path string `json:"path"`
+	// SHA256 is the hex encoded SHA-256 checksum of the model file
+	SHA256 string    `json:"sha256"`
+	Kind   ModelKind `json:"kind"`
+	// Signature lists the operations the graph is expected to contain.
+	// It replaces the default operation names of the model kind when the model is loaded.
+	Signature ModelSignature `json:"signature"`
+	// Preprocess is attached to the model when it is loaded, if set
+	Preprocess *Preprocess `json:"preprocess,omitempty"`
+	License    string      `json:"license,omitempty"`
+}
+
+// ModelSignature - Operation names of the inputs and outputs of a model, keyed by logical name.
+// Specs optionally holds the expected data type and rank of the inputs and outputs.
+type ModelSignature struct {
+	Inputs  map[string]string          `json:"inputs,omitempty"`
+	Outputs map[string]string          `json:"outputs,omitempty"`
+	Specs   map[string]ModelTensorSpec `json:"specs,omitempty"`
+}
+
+// ModelTensorSpec - Expected data type and rank of a signature tensor, such as
+// {"dtype": "float32", "rank": 4}. An empty DType or a nil Rank accepts any value.
+// The rank is only checked where the GraphDef records it, as for placeholders.
+type ModelTensorSpec struct {
+	DType string `json:"dtype,omitempty"`
+	Rank  *int   `json:"rank,omitempty"`
+}
+
+// graphDataTypes are the tensorflow DataTypes of the ModelTensorSpec dtype names
+var graphDataTypes = map[string]int{
+	"float32": dtFloat,
+	"float64": dtDouble,
+	"int32":   dtInt32,
+	"int64":   dtInt64,
+	"int16":   dtInt16,
+	"int8":    dtInt8,
+	"uint8":   dtUint8,
+	"bool":    dtBool,
+}
+
+// Registry - A manifest of named models with checksums and metadata.
+//
+// The manifest is a JSON file with a "models" list of ModelEntry values:
+//
+//	{"models": [{"name": "mtcnn", "path": "mtcnn_1.14.pb", "sha256": "...", "kind": "mtcnn",
+//	  "signature": {"inputs": {"image": "input"}, "outputs": {"box": "box"},
+//	    "specs": {"image": {"dtype": "float32", "rank": 4}}}, "license": "MIT"}]}
+type Registry struct {
+	dir    string
+	models map[string]ModelEntry
+}
+
+// ChecksumError - Error returned when a model file does not match the checksum of its registry entry
+type ChecksumError struct {
+	Name, Path       string
+	Expected, Actual string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("model %q: checksum mismatch for %s: expected sha256 %s, got %s", e.Name, e.Path, e.Expected, e.Actual)
+}
+
+// LoadRegistry reads a Registry from a manifest file. Model paths are relative to the manifest.
+func LoadRegistry(manifest string) (*Registry, error) {
+	data, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRegistry(data, filepath.Dir(manifest))
+}
+
+// ParseRegistry parses a manifest, with model paths relative to dir
+func ParseRegistry(data []byte, dir string) (*Registry, error) {
+	var manifest struct {
+		Models []ModelEntry `json:"models"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("model registry: %v", err)
+	}
+	r := &Registry{dir: dir, models: make(map[string]ModelEntry, len(manifest.Models))}
+	for _, m := range manifest.Models {
+		switch {
+		case m.Name == "":
+			return nil, fmt.Errorf("model registry: model without a name")
+		case m.Path == "":
+			return nil, fmt.Errorf("model registry: model %q has no path", m.Name)
+		case m.Kind != MTCNNModel && m.Kind != NIMAModel:
+			return nil, fmt.Errorf("model registry: model %q has unknown kind %q", m.Name, m.Kind)
+		}
+		if _, err := hex.DecodeString(m.SHA256); err != nil || len(m.SHA256) != sha256.Size*2 {
+			return nil, fmt.Errorf("model registry: model %q has an invalid sha256 %q", m.Name, m.SHA256)
+		}
+		for key, spec := range m.Signature.Specs {
+			if _, ok := graphDataTypes[spec.DType]; spec.DType != "" && !ok {
+				return nil, fmt.Errorf("model registry: model %q signature %q has unknown dtype %q", m.Name, key, spec.DType)
+			}
+		}
+		if _, ok := r.models[m.Name]; ok {
+			return nil, fmt.Errorf("model registry: duplicate model %q", m.Name)
+		}
+		m.SHA256 = strings.ToLower(m.SHA256)
+		r.models[m.Name] = m
+	}
+	return r, nil
+}
+
+// Names returns the sorted names of the models of the Registry
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.models))
+	for name := range r.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Model returns the entry of the model name
+func (r *Registry) Model(name string) (ModelEntry, error) {
+	m, ok := r.models[name]
+	if !ok {
+		return ModelEntry{}, fmt.Errorf("model registry: unknown model %q", name)
+	}
+	return m, nil
+}
+
+// Load reads the model name of kind, verifies its checksum and checks
+// that the graph contains the operations of its signature, with their specs.
+func (r *Registry) Load(name string, kind ModelKind) ([]byte, ModelEntry, error) {
+	m, err := r.Model(name)
+	if err != nil {
+		return nil, m, err
+	}
+	if m.Kind != kind {
+		return nil, m, fmt.Errorf("model registry: model %q is a %s model, expected %s", name, m.Kind, kind)
+	}
+	path := m.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.dir, path)
+	}
+	def, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, m, err
+	}
+	sum := sha256.Sum256(def)
+	if actual := hex.EncodeToString(sum[:]); actual != m.SHA256 {
+		return nil, m, &ChecksumError{Name: name, Path: path, Expected: m.SHA256, Actual: actual}
+	}
+	if err = m.Signature.check(def); err != nil {
+		return nil, m, fmt.Errorf("model registry: model %q: %v", name, err)
+	}
+	return def, m, nil
+}
+
+// check verifies that the GraphDef def contains the operations of the signature,
+// with the data types and ranks of its specs
+func (sig ModelSignature) check(def []byte) error {
+	if len(sig.Inputs) == 0 && len(sig.Outputs) == 0 {
+		return nil
+	}
+	g, err := parseGraphDef(def)
+	if err != nil {
+		return err
+	}
+	var missing, mismatched []string
+	for _, names := range []map[string]string{sig.Inputs, sig.Outputs} {
+		for _, key := range sortedKeys(names) {
+			n, err := g.node(names[key])
+			if err != nil {
+				missing = append(missing, fmt.Sprintf("%s (%s)", key, names[key]))
+				continue
+			}
+			spec, ok := sig.Specs[key]
+			if !ok {
+				continue
+			}
+			p, err := spec.check(n, names[key])
+			if err != nil {
+				return err
+			}
+			if p != "" {
+				mismatched = append(mismatched, fmt.Sprintf("%s (%s): %s", key, names[key], p))
+			}
+		}
+	}
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("graph is missing signature operations %s", strings.Join(missing, ", ")))
+	}
+	if len(mismatched) > 0 {
+		problems = append(problems, fmt.Sprintf("graph does not match signature specs: %s", strings.Join(mismatched, "; ")))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// check returns a description of how the output name of node n differs from the spec
+func (spec ModelTensorSpec) check(n *graphNode, name string) (string, error) {
+	if spec.DType != "" {
+		dtype, err := n.outputType()
+		if err != nil {
+			return "", err
+		}
+		if want := graphDataTypes[spec.DType]; dtype != 0 && dtype != want {
+			return fmt.Sprintf("dtype %s, expected %s", graphDataTypeName(dtype), spec.DType), nil
+		}
+	}
+	if spec.Rank != nil {
+		index := 0
+		if i := strings.LastIndexByte(name, ':'); i >= 0 {
+			index, _ = strconv.Atoi(name[i+1:])
+		}
+		rank, err := n.outputRank(index)
+		if err != nil {
+			return "", err
+		}
+		if rank >= 0 && rank != *spec.Rank {
+			return fmt.Sprintf("rank %d, expected %d", rank, *spec.Rank), nil
+		}
+	}
+	return "", nil
+}
+
+func graphDataTypeName(dtype int) string {
+	for name, dt := range graphDataTypes {
+		if dt == dtype {
+			return name
+		}
+	}
+	return fmt.Sprintf("DataType(%d)", dtype)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
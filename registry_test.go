@@ -0,0 +1,144 @@
+package tfimage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	reg, err := LoadRegistry(filepath.Join("models", "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range reg.Names() {
+		if _, _, err := reg.Load(name, MTCNNModel); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, _, err := reg.Load("mtcnn", NIMAModel); err == nil {
+		t.Error("wrong model kind: expected an error")
+	}
+}
+
+// testManifest writes a manifest with the first entry of models/manifest.json,
+// changed by fn, and returns its Registry
+func testManifest(t *testing.T, fn func(m *ModelEntry)) *Registry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("models", "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Models []ModelEntry `json:"models"`
+	}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	m := manifest.Models[0]
+	if m.Path, err = filepath.Abs(filepath.Join("models", m.Path)); err != nil {
+		t.Fatal(err)
+	}
+	fn(&m)
+	manifest.Models = []ModelEntry{m}
+	if data, err = json.Marshal(manifest); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestRegistryChecksumMismatch(t *testing.T) {
+	var expected string
+	reg := testManifest(t, func(m *ModelEntry) {
+		expected = strings.Repeat("0", 64)
+		m.SHA256 = expected
+	})
+	_, err := NewFaceDetectorFromRegistry(reg, "mtcnn", FaceDetectorOptions{})
+	var sumErr *ChecksumError
+	if !errors.As(err, &sumErr) {
+		t.Fatalf("error %v, want a *ChecksumError", err)
+	}
+	def := readModel(t, "mtcnn_1.14.pb")
+	sum := sha256.Sum256(def)
+	if sumErr.Expected != expected || sumErr.Actual != hex.EncodeToString(sum[:]) {
+		t.Errorf("checksum error expected %s got %s, want %s and %x", sumErr.Expected, sumErr.Actual, expected, sum)
+	}
+}
+
+func TestRegistrySignatureMismatch(t *testing.T) {
+	rank := func(r int) *int { return &r }
+	tests := []struct {
+		name    string
+		change  func(sig *ModelSignature)
+		problem string
+	}{
+		{"missing operation", func(sig *ModelSignature) {
+			sig.Outputs["box"] = "boxes"
+		}, "missing signature operations box (boxes)"},
+		{"placeholder dtype", func(sig *ModelSignature) {
+			sig.Specs["min_size"] = ModelTensorSpec{DType: "int32"}
+		}, "min_size (min_size): dtype float32, expected int32"},
+		{"output dtype", func(sig *ModelSignature) {
+			sig.Specs["prob"] = ModelTensorSpec{DType: "uint8"}
+		}, "prob (prob): dtype float32, expected uint8"},
+		{"placeholder rank", func(sig *ModelSignature) {
+			sig.Specs["thresholds"] = ModelTensorSpec{DType: "float32", Rank: rank(2)}
+		}, "thresholds (thresholds): rank 1, expected 2"},
+	}
+	for _, tt := range tests {
+		reg := testManifest(t, func(m *ModelEntry) { tt.change(&m.Signature) })
+		_, _, err := reg.Load("mtcnn", MTCNNModel)
+		if err == nil || !strings.Contains(err.Error(), tt.problem) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.problem)
+		}
+	}
+
+	if _, err := ParseRegistry([]byte(`{"models": [{"name": "m", "path": "m.pb", "kind": "mtcnn",
+		"sha256": "`+strings.Repeat("0", 64)+`", "signature": {"specs": {"image": {"dtype": "float"}}}}]}`), ""); err == nil {
+		t.Error("unknown dtype: expected an error")
+	}
+}
+
+func TestRegistrySignatureNames(t *testing.T) {
+	// the scores of the test NIMA graph are read from the logits instead of the softmax
+	dir := t.TempDir()
+	def := testNIMAGraph()
+	if err := os.WriteFile(filepath.Join(dir, "nima.pb"), def, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(def)
+	manifest := `{"models": [{"name": "nima", "path": "nima.pb", "kind": "nima", "sha256": "` + hex.EncodeToString(sum[:]) + `",
+		"signature": {"inputs": {"image": "input_1"}, "outputs": {"scores": "dense_1/MatMul"},
+		"specs": {"image": {"dtype": "float32", "rank": 4}}}}]}`
+	reg, err := ParseRegistry([]byte(manifest), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval, err := NewAestheticsEvaluatorFromRegistry(reg, "nima", AestheticsOptions{ModelOptions: ModelOptions{Backend: GoBackend}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eval.Close()
+
+	img := &ImageData{Width: 2, Height: 2, Pix: []float32{0, 9, 9, 1, 9, 9, 2, 9, 9, 3, 9, 9}}
+	scores, err := eval.model.Scores(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float32{3, 0, -3}; len(scores) != 3 || scores[0] != want[0] || scores[1] != want[1] || scores[2] != want[2] {
+		t.Errorf("scores %v, want the logits %v", scores, want)
+	}
+}
@@ -0,0 +1,182 @@
+package tfimage
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReloadableAestheticsEvaluator - An AestheticsEvaluator whose model can be replaced
+// while it is in use. Reload loads and validates a new model, then swaps it in for new
+// calls. Calls running on the previous model finish before its session is closed.
+type ReloadableAestheticsEvaluator struct {
+	options AestheticsOptions
+
+	mu      sync.RWMutex
+	current *aestheticsGeneration
+	closed  bool
+	file    string
+	modTime time.Time
+	size    int64
+}
+
+// aestheticsGeneration is a loaded model and the calls running on it
+type aestheticsGeneration struct {
+	eval *AestheticsEvaluator
+	wg   sync.WaitGroup
+}
+
+// NewReloadableAestheticsEvaluator - Creates a new Reloadable Aesthetics Evaluator from a model file
+func NewReloadableAestheticsEvaluator(modelFile string, options AestheticsOptions) (*ReloadableAestheticsEvaluator, error) {
+	r := &ReloadableAestheticsEvaluator{options: options}
+	if err := r.Reload(modelFile); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the model in modelFile and swaps it in for new calls. The current model
+// is kept if the new model fails to load or validate. Reload returns once the calls
+// running on the previous model have finished and its session is closed.
+// Reload fails once the evaluator is closed.
+func (r *ReloadableAestheticsEvaluator) Reload(modelFile string) error {
+	r.mu.RLock()
+	closed := r.closed
+	r.mu.RUnlock()
+	if closed {
+		return errModelClosed
+	}
+	info, err := os.Stat(modelFile)
+	if err != nil {
+		return err
+	}
+	def, err := ioutil.ReadFile(modelFile)
+	if err != nil {
+		return err
+	}
+	eval, err := NewAestheticsEvaluatorFromBytes(def, r.options)
+	if err != nil {
+		return fmt.Errorf("reload %s: %v", modelFile, err)
+	}
+	if err = validateAesthetics(eval); err != nil {
+		eval.Close()
+		return fmt.Errorf("reload %s: %v", modelFile, err)
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		eval.Close()
+		return errModelClosed
+	}
+	old := r.current
+	r.current = &aestheticsGeneration{eval: eval}
+	r.file, r.modTime, r.size = modelFile, info.ModTime(), info.Size()
+	r.mu.Unlock()
+
+	old.close()
+	return nil
+}
+
+// Watch polls the model file every interval and reloads it when it changes, until ctx is
+// done or the evaluator is closed. Reload errors are passed to onError, which may be nil.
+// Watch blocks, so it is usually run in its own goroutine.
+func (r *ReloadableAestheticsEvaluator) Watch(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		r.mu.RLock()
+		file, modTime, size, closed := r.file, r.modTime, r.size, r.closed
+		r.mu.RUnlock()
+		if closed {
+			return errModelClosed
+		}
+		info, err := os.Stat(file)
+		if err == nil && info.ModTime().Equal(modTime) && info.Size() == size {
+			continue
+		}
+		if err == nil {
+			err = r.Reload(file)
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// acquire returns the current generation, which must be released with wg.Done
+func (r *ReloadableAestheticsEvaluator) acquire() (*aestheticsGeneration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return nil, errModelClosed
+	}
+	r.current.wg.Add(1)
+	return r.current, nil
+}
+
+// RunImage evaluates the aesthetics of an image with the current model, returning a score from 1 to 10
+func (r *ReloadableAestheticsEvaluator) RunImage(img image.Image) (score float32, err error) {
+	g, err := r.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer g.wg.Done()
+	return g.eval.RunImage(img)
+}
+
+// Stats returns the load and warm-up timings of the current model
+func (r *ReloadableAestheticsEvaluator) Stats() ModelStats {
+	g, err := r.acquire()
+	if err != nil {
+		return ModelStats{}
+	}
+	defer g.wg.Done()
+	return g.eval.Stats()
+}
+
+// Close closes the current model once the calls running on it have finished.
+// Later calls and reloads return an error.
+func (r *ReloadableAestheticsEvaluator) Close() {
+	r.mu.Lock()
+	old := r.current
+	r.current, r.closed = nil, true
+	r.mu.Unlock()
+	old.close()
+}
+
+// close waits for the calls running on g and closes its model
+func (g *aestheticsGeneration) close() {
+	if g == nil {
+		return
+	}
+	g.wg.Wait()
+	g.eval.Close()
+}
+
+// validateAesthetics checks that eval returns a score distribution for a synthetic image
+func validateAesthetics(eval *AestheticsEvaluator) error {
+	values, err := eval.model.Scores(syntheticImage(224, 224))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("aesthetics model returned no scores")
+	}
+	for _, v := range values {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) || v < 0 {
+			return fmt.Errorf("aesthetics model returned invalid scores %v", values)
+		}
+	}
+	return nil
+}
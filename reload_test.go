@@ -0,0 +1,287 @@
+package tfimage
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scoreDistribution returns the NIMA scores of an image rated score
+func scoreDistribution(score int) []float32 {
+	d := make([]float32, 10)
+	d[score-1] = 1
+	return d
+}
+
+// fakeBackend loads fakeAestheticsModels with the scores of the model file contents.
+// Loading "slow" signals loading and waits for release to be closed.
+type fakeBackend struct {
+	models  map[string][]float32
+	loading chan struct{}
+	release chan struct{}
+
+	mu     sync.Mutex
+	loaded []*fakeAestheticsModel
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		models: map[string][]float32{
+			"a":    scoreDistribution(1),
+			"b":    scoreDistribution(10),
+			"cc":   scoreDistribution(5),
+			"nan":  {float32(math.NaN())},
+			"slow": scoreDistribution(3),
+		},
+		loading: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+}
+
+func (b *fakeBackend) LoadFaceModel(def []byte, options ModelOptions) (FaceModel, error) {
+	return nil, errors.New("no face models")
+}
+
+func (b *fakeBackend) LoadAestheticsModel(def []byte, options ModelOptions) (AestheticsModel, error) {
+	if string(def) == "slow" {
+		b.loading <- struct{}{}
+		<-b.release
+	}
+	scores, ok := b.models[string(def)]
+	if !ok {
+		return nil, errors.New("unknown model")
+	}
+	m := &fakeAestheticsModel{scores: scores}
+	b.mu.Lock()
+	b.loaded = append(b.loaded, m)
+	b.mu.Unlock()
+	return m, nil
+}
+
+// model returns the i-th model loaded by the backend
+func (b *fakeBackend) model(i int) *fakeAestheticsModel {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.loaded[i]
+}
+
+func writeModelFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestReloadable(t *testing.T, content string) (*ReloadableAestheticsEvaluator, *fakeBackend, string) {
+	t.Helper()
+	backend := newFakeBackend()
+	path := filepath.Join(t.TempDir(), "nima.pb")
+	writeModelFile(t, path, content)
+	r, err := NewReloadableAestheticsEvaluator(path, AestheticsOptions{ModelOptions: ModelOptions{Backend: backend}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, backend, path
+}
+
+var testImage = image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+func checkScore(t *testing.T, r *ReloadableAestheticsEvaluator, want float32) {
+	t.Helper()
+	if score, err := r.RunImage(testImage); err != nil || score != want {
+		t.Errorf("RunImage = %v, %v, want %v", score, err, want)
+	}
+}
+
+func TestReloadDrainsInFlightCalls(t *testing.T) {
+	r, backend, path := newTestReloadable(t, "a")
+	defer r.Close()
+	old := backend.model(0)
+	old.mu.Lock()
+	old.block, old.started = make(chan struct{}), make(chan struct{}, 1)
+	old.mu.Unlock()
+
+	type result struct {
+		score float32
+		err   error
+	}
+	inFlight := make(chan result)
+	go func() {
+		score, err := r.RunImage(testImage)
+		inFlight <- result{score, err}
+	}()
+	<-old.started
+
+	writeModelFile(t, path, "b")
+	reloaded := make(chan error)
+	go func() { reloaded <- r.Reload(path) }()
+
+	// new calls run on the new model while the old one is still in use
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.RLock()
+		swapped := r.current.eval.model != AestheticsModel(old)
+		r.mu.RUnlock()
+		if swapped {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the new model was not swapped in")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	checkScore(t, r, 10)
+	select {
+	case err := <-reloaded:
+		t.Fatalf("Reload returned %v before the in-flight call finished", err)
+	default:
+	}
+	if old.isClosed() {
+		t.Fatal("old model closed with a call in flight")
+	}
+
+	close(old.block)
+	if res := <-inFlight; res.err != nil || res.score != 1 {
+		t.Errorf("in-flight call = %v, %v, want 1 from the old model", res.score, res.err)
+	}
+	if err := <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+	if !old.isClosed() {
+		t.Error("old model not closed after its calls drained")
+	}
+	if backend.model(1).isClosed() {
+		t.Error("new model closed")
+	}
+}
+
+func TestReloadKeepsModelOnFailure(t *testing.T) {
+	r, backend, path := newTestReloadable(t, "a")
+	defer r.Close()
+
+	for _, content := range []string{"nan", "unknown"} {
+		writeModelFile(t, path, content)
+		if err := r.Reload(path); err == nil {
+			t.Errorf("%s: expected a reload error", content)
+		}
+		checkScore(t, r, 1)
+	}
+	if err := r.Reload(filepath.Join(filepath.Dir(path), "missing.pb")); err == nil {
+		t.Error("missing file: expected a reload error")
+	}
+	checkScore(t, r, 1)
+
+	if backend.model(0).isClosed() {
+		t.Error("current model closed by a failed reload")
+	}
+	// the model that failed validation is closed
+	if nan := backend.model(1); !nan.isClosed() {
+		t.Error("invalid model not closed")
+	}
+}
+
+func TestCloseDuringReload(t *testing.T) {
+	r, backend, path := newTestReloadable(t, "a")
+	writeModelFile(t, path, "slow")
+	reloaded := make(chan error)
+	go func() { reloaded <- r.Reload(path) }()
+	<-backend.loading
+
+	r.Close()
+	close(backend.release)
+	if err := <-reloaded; err != errModelClosed {
+		t.Errorf("Reload during Close = %v, want %v", err, errModelClosed)
+	}
+	if !backend.model(0).isClosed() || !backend.model(1).isClosed() {
+		t.Error("models not closed")
+	}
+	if _, err := r.RunImage(testImage); err != errModelClosed {
+		t.Errorf("RunImage after Close = %v, want %v", err, errModelClosed)
+	}
+	writeModelFile(t, path, "a")
+	if err := r.Reload(path); err != errModelClosed {
+		t.Errorf("Reload after Close = %v, want %v", err, errModelClosed)
+	}
+	if !reflect.DeepEqual(r.Stats(), ModelStats{}) {
+		t.Error("Stats after Close: want zero stats")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	r, _, path := newTestReloadable(t, "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan error)
+	go func() { watched <- r.Watch(ctx, time.Millisecond, nil) }()
+
+	waitScore := func(want float32) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if score, err := r.RunImage(testImage); err == nil && score == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Watch did not reload the model with score %v", want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// same size, newer modification time
+	writeModelFile(t, path, "b")
+	mtime := info.ModTime().Add(time.Minute)
+	if err = os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	waitScore(10)
+
+	// same modification time, new size
+	writeModelFile(t, path, "cc")
+	if err = os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	waitScore(5)
+
+	cancel()
+	if err = <-watched; err != context.Canceled {
+		t.Errorf("Watch = %v, want %v", err, context.Canceled)
+	}
+
+	r.Close()
+	if err = r.Watch(context.Background(), time.Millisecond, nil); err != errModelClosed {
+		t.Errorf("Watch after Close = %v, want %v", err, errModelClosed)
+	}
+}
+
+func TestWatchReportsErrors(t *testing.T) {
+	r, _, path := newTestReloadable(t, "a")
+	defer r.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go r.Watch(ctx, time.Millisecond, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	writeModelFile(t, path, "unknown")
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not report the reload error")
+	}
+	checkScore(t, r, 1)
+}
@@ -0,0 +1,16 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import tf "github.com/tensorflow/tensorflow/tensorflow/go"
+
+// Run evaluates the aesthetics of an image tensor with the current model, returning a score from 1 to 10
+func (r *ReloadableAestheticsEvaluator) Run(tensor *tf.Tensor) (score float32, err error) {
+	g, err := r.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer g.wg.Done()
+	return g.eval.Run(tensor)
+}
@@ -0,0 +1,52 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"fmt"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// NewModelFromSavedModel - Creates a new Model from a SavedModel directory.
+//
+// The tensors of the Signature are resolved from the SignatureDef selected by
+// options.SavedModel. Each logical name of sig is looked up in the SignatureDef
+// by name, then by the operation name given in sig. A Signature with a single
+// input or output is matched to a SignatureDef with a single input or output.
+func NewModelFromSavedModel(exportDir string, sig Signature, options ModelOptions) (*Model, error) {
+	tags := options.SavedModel.Tags
+	if len(tags) == 0 {
+		tags = []string{DefaultSavedModelTag}
+	}
+	key := options.SavedModel.SignatureKey
+	if key == "" {
+		key = DefaultSavedModelSignature
+	}
+
+	def, err := readSignatureDef(exportDir, tags, key)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := Signature{Specs: sig.Specs}
+	if resolved.Inputs, err = def.inputs.match(sig.Inputs); err != nil {
+		return nil, fmt.Errorf("saved model %s inputs: %v", key, err)
+	}
+	if resolved.Outputs, err = def.outputs.match(sig.Outputs); err != nil {
+		return nil, fmt.Errorf("saved model %s outputs: %v", key, err)
+	}
+
+	sm, err := tf.LoadSavedModel(exportDir, tags, options.Session.tfOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Model{graph: sm.Graph, session: sm.Session, signature: resolved, options: options}
+	if m.inputs, m.outputs, err = resolved.resolve(m.graph); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,165 @@
+package tfimage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// signatureTensors maps the keys of a SignatureDef's inputs or outputs to tensor names
+type signatureTensors map[string]string
+
+// match returns the tensor names of the logical names of a Signature
+func (st signatureTensors) match(names map[string]string) (map[string]string, error) {
+	res := make(map[string]string, len(names))
+	for name, opName := range names {
+		if tensor, ok := st[name]; ok {
+			res[name] = tensor
+		} else if tensor, ok := st[opName]; ok {
+			res[name] = tensor
+		} else if len(names) == 1 && len(st) == 1 {
+			for _, tensor := range st {
+				res[name] = tensor
+			}
+		} else {
+			return nil, fmt.Errorf("%q not found, signature has %s", name, strings.Join(st.keys(), ", "))
+		}
+	}
+	return res, nil
+}
+
+func (st signatureTensors) keys() []string {
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// signatureDef is the part of a tensorflow SignatureDef needed to resolve a Signature
+type signatureDef struct {
+	inputs, outputs signatureTensors
+}
+
+// SavedModel proto field numbers from tensorflow/core/protobuf/saved_model.proto,
+// meta_graph.proto
+const (
+	savedModelMetaGraphs = 2
+
+	metaGraphInfo         = 1
+	metaGraphSignatureDef = 5
+
+	metaInfoTags = 4
+
+	signatureInputs  = 1
+	signatureOutputs = 2
+
+	tensorInfoName = 1
+)
+
+// readSignatureDef reads the SignatureDef key of the MetaGraph tagged with tags from exportDir
+func readSignatureDef(exportDir string, tags []string, key string) (def signatureDef, err error) {
+	b, err := ioutil.ReadFile(filepath.Join(exportDir, "saved_model.pb"))
+	if os.IsNotExist(err) {
+		if _, e := os.Stat(filepath.Join(exportDir, "saved_model.pbtxt")); e == nil {
+			return def, fmt.Errorf("saved model %s: text format saved_model.pbtxt is not supported", exportDir)
+		}
+	}
+	if err != nil {
+		return def, err
+	}
+
+	var keys []string
+	found := false
+	err = decodeProto(b, func(f protoField) error {
+		if f.Num != savedModelMetaGraphs || found {
+			return nil
+		}
+		var graphTags []string
+		sigs := map[string][]byte{}
+		err := decodeProto(f.Bytes, func(f protoField) error {
+			switch f.Num {
+			case metaGraphInfo:
+				return decodeProto(f.Bytes, func(f protoField) error {
+					if f.Num == metaInfoTags {
+						graphTags = append(graphTags, string(f.Bytes))
+					}
+					return nil
+				})
+			case metaGraphSignatureDef:
+				k, v, err := decodeMapEntry(f.Bytes)
+				sigs[k] = v
+				return err
+			}
+			return nil
+		})
+		if err != nil || !hasTags(graphTags, tags) {
+			return err
+		}
+
+		sig, ok := sigs[key]
+		if !ok {
+			for k := range sigs {
+				keys = append(keys, k)
+			}
+			return nil
+		}
+		found = true
+		def, err = decodeSignatureDef(sig)
+		return err
+	})
+	if err != nil {
+		return def, fmt.Errorf("saved model %s: %v", exportDir, err)
+	}
+	if !found {
+		sort.Strings(keys)
+		return def, fmt.Errorf("saved model %s: signature %q with tags %v not found, signatures: %s", exportDir, key, tags, strings.Join(keys, ", "))
+	}
+	return def, nil
+}
+
+func decodeSignatureDef(b []byte) (def signatureDef, err error) {
+	def.inputs, def.outputs = signatureTensors{}, signatureTensors{}
+	err = decodeProto(b, func(f protoField) error {
+		var tensors signatureTensors
+		switch f.Num {
+		case signatureInputs:
+			tensors = def.inputs
+		case signatureOutputs:
+			tensors = def.outputs
+		default:
+			return nil
+		}
+		k, v, err := decodeMapEntry(f.Bytes)
+		if err != nil {
+			return err
+		}
+		return decodeProto(v, func(f protoField) error {
+			if f.Num == tensorInfoName {
+				tensors[k] = string(f.Bytes)
+			}
+			return nil
+		})
+	})
+	return def, err
+}
+
+// hasTags reports whether graphTags and tags are the same set of tags, as
+// LoadSavedModel requires an exact match
+func hasTags(graphTags, tags []string) bool {
+	want, got := map[string]bool{}, map[string]bool{}
+	for _, t := range tags {
+		want[t] = true
+	}
+	for _, g := range graphTags {
+		if !want[g] {
+			return false
+		}
+		got[g] = true
+	}
+	return len(got) == len(want)
+}
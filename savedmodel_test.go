@@ -0,0 +1,111 @@
+package tfimage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testMetaGraph encodes a MetaGraphDef with tags and a "serving_default"
+// SignatureDef that has a single input and output named after the tags
+func testMetaGraph(tags ...string) []byte {
+	var info []byte
+	for _, t := range tags {
+		info = appendBytes(info, metaInfoTags, []byte(t))
+	}
+	name := strings.Join(tags, "_")
+	tensor := func(key string) []byte {
+		ti := appendBytes(nil, tensorInfoName, []byte(name+"/"+key+":0"))
+		return appendBytes(appendBytes(nil, mapKey, []byte(key)), mapValue, ti)
+	}
+	var sig []byte
+	sig = appendBytes(sig, signatureInputs, tensor("x"))
+	sig = appendBytes(sig, signatureOutputs, tensor("y"))
+	entry := appendBytes(appendBytes(nil, mapKey, []byte(DefaultSavedModelSignature)), mapValue, sig)
+
+	var mg []byte
+	mg = appendBytes(mg, metaGraphInfo, info)
+	return appendBytes(mg, metaGraphSignatureDef, entry)
+}
+
+// writeSavedModel writes a saved_model.pb with the meta graphs to a temporary directory
+func writeSavedModel(t *testing.T, metaGraphs ...[]byte) string {
+	t.Helper()
+	var b []byte
+	for _, mg := range metaGraphs {
+		b = appendBytes(b, savedModelMetaGraphs, mg)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "saved_model.pb"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReadSignatureDefTags(t *testing.T) {
+	tests := []struct {
+		name       string
+		graphs     [][]string
+		tags       []string
+		wantPrefix string // "" when no MetaGraph matches
+	}{
+		{"exact", [][]string{{"serve"}}, []string{"serve"}, "serve"},
+		{"order", [][]string{{"gpu", "serve"}}, []string{"serve", "gpu"}, "gpu_serve"},
+		{"duplicate tag", [][]string{{"serve"}}, []string{"serve", "serve"}, "serve"},
+		{"graph superset", [][]string{{"serve", "gpu"}}, []string{"serve"}, ""},
+		{"graph subset", [][]string{{"serve"}}, []string{"serve", "gpu"}, ""},
+		{"disjoint", [][]string{{"train"}}, []string{"serve"}, ""},
+		{"untagged graph", [][]string{{}}, []string{"serve"}, ""},
+		{"second graph", [][]string{{"serve", "gpu"}, {"serve"}}, []string{"serve"}, "serve"},
+		{"first match", [][]string{{"serve", "tpu"}, {"serve", "gpu"}, {"serve"}}, []string{"gpu", "serve"}, "serve_gpu"},
+	}
+	for _, tt := range tests {
+		var graphs [][]byte
+		for _, tags := range tt.graphs {
+			graphs = append(graphs, testMetaGraph(tags...))
+		}
+		dir := writeSavedModel(t, graphs...)
+
+		def, err := readSignatureDef(dir, tt.tags, DefaultSavedModelSignature)
+		if tt.wantPrefix == "" {
+			if err == nil {
+				t.Errorf("%s: tags %v matched %v, want no match", tt.name, tt.tags, def.inputs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := signatureDef{
+			inputs:  signatureTensors{"x": tt.wantPrefix + "/x:0"},
+			outputs: signatureTensors{"y": tt.wantPrefix + "/y:0"},
+		}
+		if !reflect.DeepEqual(def, want) {
+			t.Errorf("%s: signature %+v, want %+v", tt.name, def, want)
+		}
+	}
+}
+
+func TestReadSignatureDefErrors(t *testing.T) {
+	dir := writeSavedModel(t, testMetaGraph("serve"))
+	_, err := readSignatureDef(dir, []string{"serve"}, "predict")
+	if err == nil || !strings.Contains(err.Error(), DefaultSavedModelSignature) {
+		t.Errorf("unknown signature key: error %v, want the available signatures", err)
+	}
+
+	dir = t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, "saved_model.pbtxt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readSignatureDef(dir, []string{"serve"}, DefaultSavedModelSignature); err == nil || !strings.Contains(err.Error(), "pbtxt") {
+		t.Errorf("text format saved model: error %v, want pbtxt is not supported", err)
+	}
+
+	dir = writeSavedModel(t, []byte{0x0a, 0x05, 0x01})
+	if _, err = readSignatureDef(dir, []string{"serve"}, DefaultSavedModelSignature); err == nil {
+		t.Error("truncated saved model: expected an error")
+	}
+}
@@ -0,0 +1,73 @@
+package tfimage
+
+// OptimizerLevel - graph optimization level of a Session
+type OptimizerLevel uint8
+
+// Optimizer levels
+const (
+	// OptimizerL1 enables common subexpression elimination and constant folding (tensorflow's default)
+	OptimizerL1 OptimizerLevel = iota
+	// OptimizerL0 disables graph optimizations
+	OptimizerL0
+	// OptimizerJIT enables OptimizerL1 and XLA JIT compilation, when supported by libtensorflow
+	OptimizerJIT
+)
+
+// SessionOptions - Configuration of the tensorflow Session of a Model.
+// The zero value uses tensorflow's defaults.
+type SessionOptions struct {
+	// IntraOpThreads is the number of threads used to parallelize a single operation.
+	// Zero lets tensorflow choose.
+	IntraOpThreads int
+	// InterOpThreads is the number of threads used to run independent operations.
+	// Zero lets tensorflow choose.
+	InterOpThreads int
+	// PerSessionThreads gives the Session its own thread pools, instead of the
+	// process wide pools that are sized by the first Session created.
+	PerSessionThreads bool
+
+	Optimizer OptimizerLevel
+}
+
+// ConfigProto field numbers from tensorflow/core/protobuf/config.proto
+const (
+	configIntraOpThreads       = 2
+	configInterOpThreads       = 5
+	configUsePerSessionThreads = 9
+	configGraphOptions         = 10
+
+	graphOptimizerOptions = 3
+
+	optimizerCSE            = 1
+	optimizerConstantFold   = 2
+	optimizerOptLevel       = 3
+	optimizerGlobalJITLevel = 5
+)
+
+// Config serializes the SessionOptions to a tensorflow ConfigProto
+func (o SessionOptions) Config() []byte {
+	var b []byte
+	if o.IntraOpThreads > 0 {
+		b = appendInt(b, configIntraOpThreads, int64(o.IntraOpThreads))
+	}
+	if o.InterOpThreads > 0 {
+		b = appendInt(b, configInterOpThreads, int64(o.InterOpThreads))
+	}
+	if o.PerSessionThreads {
+		b = appendBool(b, configUsePerSessionThreads, true)
+	}
+
+	var opt []byte
+	switch o.Optimizer {
+	case OptimizerL0:
+		opt = appendBool(opt, optimizerCSE, false)
+		opt = appendBool(opt, optimizerConstantFold, false)
+		opt = appendInt(opt, optimizerOptLevel, -1) // L0
+	case OptimizerJIT:
+		opt = appendInt(opt, optimizerGlobalJITLevel, 1) // ON_1
+	}
+	if len(opt) > 0 {
+		b = appendBytes(b, configGraphOptions, appendBytes(nil, graphOptimizerOptions, opt))
+	}
+	return b
+}
@@ -0,0 +1,69 @@
+package tfimage
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Golden ConfigProto encodings, as serialized by the protobuf library. For example
+// tf.compat.v1.ConfigProto(intra_op_parallelism_threads=4, inter_op_parallelism_threads=2)
+// serializes to b'\x10\x04(\x02'.
+func TestSessionOptionsConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		o    SessionOptions
+		want []byte
+	}{
+		{"zero", SessionOptions{}, nil},
+		{"threads", SessionOptions{IntraOpThreads: 4, InterOpThreads: 2}, []byte{0x10, 0x04, 0x28, 0x02}},
+		{"large thread count", SessionOptions{IntraOpThreads: 300}, []byte{0x10, 0xac, 0x02}},
+		{"per session threads", SessionOptions{PerSessionThreads: true}, []byte{0x48, 0x01}},
+		{"negative threads", SessionOptions{IntraOpThreads: -1}, nil},
+		{"L0", SessionOptions{Optimizer: OptimizerL0}, []byte{
+			0x52, 0x11, // graph_options
+			0x1a, 0x0f, // optimizer_options
+			0x08, 0x00, // do_common_subexpression_elimination: false
+			0x10, 0x00, // do_constant_folding: false
+			0x18, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // opt_level: L0 (-1)
+		}},
+		{"JIT", SessionOptions{Optimizer: OptimizerJIT}, []byte{
+			0x52, 0x04, // graph_options
+			0x1a, 0x02, // optimizer_options
+			0x28, 0x01, // global_jit_level: ON_1
+		}},
+		{"all", SessionOptions{IntraOpThreads: 1, InterOpThreads: 1, PerSessionThreads: true, Optimizer: OptimizerJIT}, []byte{
+			0x10, 0x01, 0x28, 0x01, 0x48, 0x01, 0x52, 0x04, 0x1a, 0x02, 0x28, 0x01,
+		}},
+	}
+	for _, tt := range tests {
+		if got := tt.o.Config(); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: Config() = % x, want % x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeProto(t *testing.T) {
+	b := SessionOptions{IntraOpThreads: 300, Optimizer: OptimizerL0}.Config()
+	var fields []protoField
+	if err := decodeProto(b, func(f protoField) error {
+		fields = append(fields, f)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("decoded %d fields, want 2", len(fields))
+	}
+	if f := fields[0]; f.Num != configIntraOpThreads || f.Wire != wireVarint || f.Varint != 300 {
+		t.Errorf("field 0 = %+v, want intra_op_parallelism_threads 300", f)
+	}
+	if f := fields[1]; f.Num != configGraphOptions || f.Wire != wireBytes || len(f.Bytes) != 17 {
+		t.Errorf("field 1 = %+v, want 17 bytes of graph_options", f)
+	}
+
+	for _, truncated := range [][]byte{b[:1], b[:len(b)-1], {0x80}} {
+		if err := decodeProto(truncated, func(protoField) error { return nil }); err != errProtoTruncated {
+			t.Errorf("decodeProto(% x) error %v, want %v", truncated, err, errProtoTruncated)
+		}
+	}
+}
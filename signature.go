@@ -0,0 +1,164 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
+package tfimage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// AnyRank is a TensorSpec Rank that accepts tensors of any rank
+const AnyRank = -1
+
+// TensorSpec - Expected data type and rank of a signature tensor.
+// A zero DType accepts any data type.
+type TensorSpec struct {
+	DType tf.DataType
+	Rank  int
+}
+
+// Signature maps the logical input and output names of a Model to the
+// operations of its graph. An operation name may select an output other
+// than the first with an index suffix, for example "split:1".
+//
+// Specs optionally holds the expected data type and rank of the
+// inputs and outputs, keyed by logical name.
+type Signature struct {
+	Inputs  map[string]string
+	Outputs map[string]string
+	Specs   map[string]TensorSpec
+}
+
+// SignatureError - Error returned when a graph does not match a Signature.
+// It lists the problems found and the inputs and outputs the graph does contain.
+type SignatureError struct {
+	Problems []string
+	Inputs   []string // placeholders of the graph
+	Outputs  []string // operations of the graph that have no consumers
+}
+
+func (e *SignatureError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString("model graph does not match signature: ")
+	sb.WriteString(strings.Join(e.Problems, "; "))
+	sb.WriteString(fmt.Sprintf("\n graph inputs: %s", strings.Join(e.Inputs, ", ")))
+	sb.WriteString(fmt.Sprintf("\n graph outputs: %s", strings.Join(e.Outputs, ", ")))
+	return sb.String()
+}
+
+// withNames returns a copy of sig with the operation names of its inputs and outputs
+// replaced by those of names. Logical names that sig does not have are an error.
+func (sig Signature) withNames(names ModelSignature) (Signature, error) {
+	res := Signature{Inputs: map[string]string{}, Outputs: map[string]string{}, Specs: sig.Specs}
+	for _, m := range []struct{ dst, src, override map[string]string }{
+		{res.Inputs, sig.Inputs, names.Inputs},
+		{res.Outputs, sig.Outputs, names.Outputs},
+	} {
+		for k, v := range m.src {
+			m.dst[k] = v
+		}
+		for _, k := range sortedKeys(m.override) {
+			if _, ok := m.src[k]; !ok {
+				return Signature{}, fmt.Errorf("signature has no tensor %q, expected one of %s", k, strings.Join(sortedKeys(m.src), ", "))
+			}
+			m.dst[k] = m.override[k]
+		}
+	}
+	return res, nil
+}
+
+// resolve looks up the inputs and outputs of the Signature in graph and validates them against the Specs
+func (sig Signature) resolve(graph *tf.Graph) (inputs, outputs map[string]tf.Output, err error) {
+	var problems []string
+	lookup := func(names map[string]string) map[string]tf.Output {
+		res := make(map[string]tf.Output, len(names))
+		for _, name := range sortedKeys(names) {
+			output, err := graphOutput(graph, names[name])
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%q: %v", name, err))
+				continue
+			}
+			if spec, ok := sig.Specs[name]; ok {
+				if p := spec.check(output); p != "" {
+					problems = append(problems, fmt.Sprintf("%q (%s): %s", name, names[name], p))
+				}
+			}
+			res[name] = output
+		}
+		return res
+	}
+	inputs = lookup(sig.Inputs)
+	outputs = lookup(sig.Outputs)
+
+	if len(problems) > 0 {
+		e := &SignatureError{Problems: problems}
+		e.Inputs, e.Outputs = graphEndpoints(graph)
+		return nil, nil, e
+	}
+	return inputs, outputs, nil
+}
+
+// check returns a description of how output differs from the TensorSpec
+func (spec TensorSpec) check(output tf.Output) string {
+	if spec.DType != 0 && output.DataType() != spec.DType {
+		return fmt.Sprintf("dtype %s, expected %s", dataTypeName(output.DataType()), dataTypeName(spec.DType))
+	}
+	// An unknown rank can only be checked at run time
+	if rank := output.Shape().NumDimensions(); spec.Rank != AnyRank && rank >= 0 && rank != spec.Rank {
+		return fmt.Sprintf("rank %d, expected %d", rank, spec.Rank)
+	}
+	return ""
+}
+
+// graphOutput returns the output of an operation named "op" or "op:index"
+func graphOutput(graph *tf.Graph, name string) (tf.Output, error) {
+	index := 0
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		n, err := strconv.Atoi(name[i+1:])
+		if err != nil {
+			return tf.Output{}, fmt.Errorf("invalid output name %q", name)
+		}
+		name, index = name[:i], n
+	}
+
+	operation := graph.Operation(name)
+	if operation == nil {
+		return tf.Output{}, fmt.Errorf("operation %q not found in graph", name)
+	}
+	if index >= operation.NumOutputs() {
+		return tf.Output{}, fmt.Errorf("operation %q has %d outputs", name, operation.NumOutputs())
+	}
+	return operation.Output(index), nil
+}
+
+// graphEndpoints describes the placeholders of graph and the operations whose outputs are not consumed
+func graphEndpoints(graph *tf.Graph) (inputs, outputs []string) {
+	for _, operation := range graph.Operations() {
+		switch operation.Type() {
+		case "Placeholder":
+			o := operation.Output(0)
+			inputs = append(inputs, fmt.Sprintf("%s (%s %s)", operation.Name(), dataTypeName(o.DataType()), o.Shape()))
+		case "Const", "NoOp", "Assert":
+		default:
+			consumed := false
+			for i := 0; i < operation.NumOutputs(); i++ {
+				if len(operation.Output(i).Consumers()) > 0 {
+					consumed = true
+					break
+				}
+			}
+			if !consumed && operation.NumOutputs() > 0 {
+				o := operation.Output(0)
+				outputs = append(outputs, fmt.Sprintf("%s (%s %s)", operation.Name(), dataTypeName(o.DataType()), o.Shape()))
+			}
+		}
+	}
+	sort.Strings(inputs)
+	sort.Strings(outputs)
+	return inputs, outputs
+}
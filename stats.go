@@ -0,0 +1,87 @@
+package tfimage
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"time"
+)
+
+// WarmupOptions - Synthetic inputs that the constructors run before returning a model,
+// so that the lazy initialization of the backend does not slow down the first call.
+type WarmupOptions struct {
+	// Sizes are the width and height of the synthetic images. No sizes disables the warm-up.
+	Sizes []image.Point
+	// Runs is the number of runs per size, defaults to 1
+	Runs int
+}
+
+// ModelStats - Load and warm-up timings of a model, to be logged at startup
+type ModelStats struct {
+	LoadTime   time.Duration
+	WarmupTime time.Duration
+	Latency    []SizeLatency // latency of the warm-up runs per size
+}
+
+// SizeLatency - Latency of the warm-up runs of an image size
+type SizeLatency struct {
+	Size image.Point
+	Runs int
+	// First is the latency of the first run, Mean the mean latency of all runs
+	First, Mean time.Duration
+}
+
+func (s ModelStats) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("Load: %s \t Warm-up: %s \n", s.LoadTime, s.WarmupTime))
+	for _, l := range s.Latency {
+		sb.WriteString(fmt.Sprintf(" %dx%d: \t First: %s \t Mean: %s (%d runs) \n", l.Size.X, l.Size.Y, l.First, l.Mean, l.Runs))
+	}
+	return sb.String()
+}
+
+// warmup runs fn on synthetic images of the sizes of o and records the latencies in stats
+func (o WarmupOptions) warmup(stats *ModelStats, fn func(img *ImageData) error) error {
+	runs := o.Runs
+	if runs <= 0 {
+		runs = 1
+	}
+	start := time.Now()
+	for _, size := range o.Sizes {
+		if size.X <= 0 || size.Y <= 0 {
+			return fmt.Errorf("invalid warm-up size %dx%d", size.X, size.Y)
+		}
+		img := syntheticImage(size.X, size.Y)
+		l := SizeLatency{Size: size, Runs: runs}
+		var total time.Duration
+		for i := 0; i < runs; i++ {
+			t := time.Now()
+			if err := fn(img); err != nil {
+				return fmt.Errorf("warm-up %dx%d: %v", size.X, size.Y, err)
+			}
+			d := time.Since(t)
+			if i == 0 {
+				l.First = d
+			}
+			total += d
+		}
+		l.Mean = total / time.Duration(runs)
+		stats.Latency = append(stats.Latency, l)
+	}
+	stats.WarmupTime = time.Since(start)
+	return nil
+}
+
+// syntheticImage returns a gradient image of width w and height h
+func syntheticImage(w, h int) *ImageData {
+	img := &ImageData{Width: w, Height: h, Pix: make([]float32, w*h*3)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := (y*w + x) * 3
+			img.Pix[i] = float32(x * 255 / w)
+			img.Pix[i+1] = float32(y * 255 / h)
+			img.Pix[i+2] = 128
+		}
+	}
+	return img
+}
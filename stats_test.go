@@ -0,0 +1,101 @@
+package tfimage
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWarmupStats(t *testing.T) {
+	model := &fakeAestheticsModel{scores: []float32{1}}
+	warmup := WarmupOptions{Sizes: []image.Point{{64, 48}, {32, 32}}, Runs: 3}
+	start := time.Now().Add(-time.Second)
+	eval, err := NewAestheticsEvaluatorFromAestheticsModel(model).loaded(start, warmup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []image.Point{{64, 48}, {64, 48}, {64, 48}, {32, 32}, {32, 32}, {32, 32}}
+	if !reflect.DeepEqual(model.sizes, want) {
+		t.Errorf("warm-up sizes %v, want %v", model.sizes, want)
+	}
+	stats := eval.Stats()
+	if stats.LoadTime < time.Second {
+		t.Errorf("load time %s, want the time since start", stats.LoadTime)
+	}
+	if len(stats.Latency) != 2 {
+		t.Fatalf("%d latencies, want 2", len(stats.Latency))
+	}
+	var total time.Duration
+	for i, l := range stats.Latency {
+		if l.Size != warmup.Sizes[i] || l.Runs != 3 {
+			t.Errorf("latency %d is %dx%d with %d runs, want %dx%d with 3 runs", i, l.Size.X, l.Size.Y, l.Runs, warmup.Sizes[i].X, warmup.Sizes[i].Y)
+		}
+		if l.First < 0 || l.Mean < 0 || l.First > l.Mean*time.Duration(l.Runs) {
+			t.Errorf("latency %d: first %s and mean %s of %d runs", i, l.First, l.Mean, l.Runs)
+		}
+		total += l.Mean * time.Duration(l.Runs)
+	}
+	if stats.WarmupTime < total {
+		t.Errorf("warm-up time %s, want at least the %s of the runs", stats.WarmupTime, total)
+	}
+	if s := stats.String(); !strings.Contains(s, "64x48") || !strings.Contains(s, "(3 runs)") {
+		t.Errorf("String() = %q, want the sizes and runs", s)
+	}
+	if model.isClosed() {
+		t.Error("model closed after a successful warm-up")
+	}
+}
+
+func TestWarmupRuns(t *testing.T) {
+	tests := []struct {
+		name   string
+		warmup WarmupOptions
+		calls  int
+	}{
+		{"disabled", WarmupOptions{}, 0},
+		{"disabled with runs", WarmupOptions{Runs: 5}, 0},
+		{"default runs", WarmupOptions{Sizes: []image.Point{{8, 8}}}, 1},
+		{"negative runs", WarmupOptions{Sizes: []image.Point{{8, 8}}, Runs: -1}, 1},
+		{"runs", WarmupOptions{Sizes: []image.Point{{8, 8}, {4, 4}}, Runs: 2}, 4},
+	}
+	for _, tt := range tests {
+		model := &fakeFaceModel{}
+		det, err := NewFaceDetectorFromFaceModel(model, FaceDetectorOptions{MinimumSize: 20}).loaded(time.Now(), tt.warmup)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(model.sizes) != tt.calls {
+			t.Errorf("%s: %d warm-up calls, want %d", tt.name, len(model.sizes), tt.calls)
+		}
+		if n := len(det.Stats().Latency); n != len(tt.warmup.Sizes) {
+			t.Errorf("%s: %d latencies, want %d", tt.name, n, len(tt.warmup.Sizes))
+		}
+		for _, p := range model.params {
+			if p != det.params() {
+				t.Errorf("%s: warm-up params %+v, want %+v", tt.name, p, det.params())
+			}
+		}
+	}
+}
+
+func TestWarmupErrors(t *testing.T) {
+	model := &fakeAestheticsModel{err: errors.New("no scores")}
+	if _, err := NewAestheticsEvaluatorFromAestheticsModel(model).loaded(time.Now(), WarmupOptions{Sizes: []image.Point{{8, 8}}}); err == nil || !strings.Contains(err.Error(), "no scores") {
+		t.Errorf("failed warm-up: error %v, want the model error", err)
+	}
+	if !model.isClosed() {
+		t.Error("model not closed after a failed warm-up")
+	}
+
+	model = &fakeAestheticsModel{}
+	if _, err := NewAestheticsEvaluatorFromAestheticsModel(model).loaded(time.Now(), WarmupOptions{Sizes: []image.Point{{0, 8}}}); err == nil {
+		t.Error("invalid warm-up size: expected an error")
+	}
+	if len(model.sizes) != 0 || !model.isClosed() {
+		t.Errorf("invalid warm-up size: %d calls and closed %v, want 0 calls and closed", len(model.sizes), model.isClosed())
+	}
+}
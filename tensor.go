@@ -1,3 +1,6 @@
+//go:build cgo && !notensorflow
+// +build cgo,!notensorflow
+
 package tfimage
 
 import (
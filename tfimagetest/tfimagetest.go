@@ -0,0 +1,174 @@
+// Package tfimagetest provides scripted fakes of the tfimage Detector and
+// AestheticScorer, so that code using them can be tested without
+// libtensorflow or model files.
+package tfimagetest
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/evanoberholster/tfimage"
+)
+
+// ErrClosed is returned by the fakes once they are closed
+var ErrClosed = errors.New("tfimagetest: closed")
+
+// Call - A call made to a fake
+type Call struct {
+	Image image.Image
+	Time  time.Time
+}
+
+// DetectResponse - A scripted response of a Detector
+type DetectResponse struct {
+	Faces   []tfimage.Face
+	Err     error
+	Latency time.Duration // the call sleeps for Latency before responding
+}
+
+// Detector - A fake tfimage.Detector that returns scripted responses
+type Detector struct {
+	mu     sync.Mutex
+	script []DetectResponse
+	calls  []Call
+	closed bool
+}
+
+// NewDetector creates a Detector that returns the responses in order. The last response
+// is repeated once the script is exhausted, and no responses detect no faces.
+func NewDetector(responses ...DetectResponse) *Detector {
+	return &Detector{script: responses}
+}
+
+// DetectImage records the call and returns the next scripted response
+func (d *Detector) DetectImage(img image.Image) (*tfimage.FaceResults, error) {
+	d.mu.Lock()
+	i := len(d.calls)
+	d.calls = append(d.calls, Call{Image: img, Time: time.Now()})
+	closed := d.closed
+	var res DetectResponse
+	if len(d.script) > 0 {
+		res = d.script[minInt(i, len(d.script)-1)]
+	}
+	d.mu.Unlock()
+
+	if closed {
+		return nil, ErrClosed
+	}
+	time.Sleep(res.Latency)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return tfimage.NewFaceResults(res.Faces, res.Latency), nil
+}
+
+// Calls returns the calls made to DetectImage
+func (d *Detector) Calls() []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Call(nil), d.calls...)
+}
+
+// Close closes the Detector, later calls return ErrClosed
+func (d *Detector) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+}
+
+// Closed reports whether Close was called
+func (d *Detector) Closed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// ScoreResponse - A scripted response of a Scorer
+type ScoreResponse struct {
+	Score   float32
+	Err     error
+	Latency time.Duration // the call sleeps for Latency before responding
+}
+
+// Scorer - A fake tfimage.AestheticScorer that returns scripted responses
+type Scorer struct {
+	mu     sync.Mutex
+	script []ScoreResponse
+	calls  []Call
+	closed bool
+}
+
+// NewScorer creates a Scorer that returns the responses in order. The last response
+// is repeated once the script is exhausted, and no responses score 0.
+func NewScorer(responses ...ScoreResponse) *Scorer {
+	return &Scorer{script: responses}
+}
+
+// RunImage records the call and returns the next scripted response
+func (s *Scorer) RunImage(img image.Image) (float32, error) {
+	s.mu.Lock()
+	i := len(s.calls)
+	s.calls = append(s.calls, Call{Image: img, Time: time.Now()})
+	closed := s.closed
+	var res ScoreResponse
+	if len(s.script) > 0 {
+		res = s.script[minInt(i, len(s.script)-1)]
+	}
+	s.mu.Unlock()
+
+	if closed {
+		return 0, ErrClosed
+	}
+	time.Sleep(res.Latency)
+	return res.Score, res.Err
+}
+
+// Calls returns the calls made to RunImage
+func (s *Scorer) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Close closes the Scorer, later calls return ErrClosed
+func (s *Scorer) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+// Closed reports whether Close was called
+func (s *Scorer) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Face creates a Face in box with probability p, with landmarks at the usual
+// positions of an upright face, in the order of the detector output.
+func Face(box image.Rectangle, p float32) tfimage.Face {
+	x0, y0 := float32(box.Min.X), float32(box.Min.Y)
+	w, h := float32(box.Dx()), float32(box.Dy())
+	// left eye, right eye, nose, left mouth and right mouth, relative to the box
+	points := [5][2]float32{{0.3, 0.4}, {0.7, 0.4}, {0.5, 0.55}, {0.35, 0.75}, {0.65, 0.75}}
+	var landmarks [10]float32
+	for i, pt := range points {
+		landmarks[i+5] = x0 + pt[0]*w
+		landmarks[i] = y0 + pt[1]*h
+	}
+	return tfimage.NewFace(p, [4]float32{y0, x0, float32(box.Max.Y), float32(box.Max.X)}, landmarks)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var (
+	_ tfimage.Detector        = (*Detector)(nil)
+	_ tfimage.AestheticScorer = (*Scorer)(nil)
+)
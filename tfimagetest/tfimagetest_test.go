@@ -0,0 +1,162 @@
+package tfimagetest
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/evanoberholster/tfimage"
+)
+
+func TestDetectorScript(t *testing.T) {
+	errDetect := errors.New("detect failed")
+	face := Face(image.Rect(10, 20, 110, 140), 0.9)
+	d := NewDetector(
+		DetectResponse{Faces: []tfimage.Face{face}},
+		DetectResponse{Err: errDetect},
+	)
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	res, err := d.DetectImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Faces(); len(got) != 1 || !reflect.DeepEqual(got[0], face) {
+		t.Errorf("first call: faces %v, want %v", got, face)
+	}
+	// the last response is repeated once the script is exhausted
+	for i := 0; i < 2; i++ {
+		if _, err = d.DetectImage(img); err != errDetect {
+			t.Errorf("call %d: error %v, want %v", i+2, err, errDetect)
+		}
+	}
+
+	calls := d.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("%d calls, want 3", len(calls))
+	}
+	for i, c := range calls {
+		if c.Image != img {
+			t.Errorf("call %d: image not recorded", i)
+		}
+		if i > 0 && c.Time.Before(calls[i-1].Time) {
+			t.Errorf("call %d: time %v before the previous call", i, c.Time)
+		}
+	}
+	// Calls returns a copy
+	calls[0].Image = nil
+	if d.Calls()[0].Image != img {
+		t.Error("Calls shares its slice with the Detector")
+	}
+}
+
+func TestDetectorEmptyScript(t *testing.T) {
+	res, err := NewDetector().DetectImage(image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Len() != 0 {
+		t.Errorf("%d faces, want none", res.Len())
+	}
+}
+
+func TestDetectorClose(t *testing.T) {
+	d := NewDetector(DetectResponse{Faces: []tfimage.Face{Face(image.Rect(0, 0, 10, 10), 1)}})
+	if d.Closed() {
+		t.Fatal("new Detector is closed")
+	}
+	d.Close()
+	if !d.Closed() {
+		t.Fatal("Detector not closed after Close")
+	}
+	if _, err := d.DetectImage(image.NewGray(image.Rect(0, 0, 1, 1))); err != ErrClosed {
+		t.Errorf("error %v, want ErrClosed", err)
+	}
+	// calls are recorded after Close
+	if n := len(d.Calls()); n != 1 {
+		t.Errorf("%d calls, want 1", n)
+	}
+}
+
+func TestDetectorLatency(t *testing.T) {
+	const latency = 20 * time.Millisecond
+	d := NewDetector(DetectResponse{Latency: latency})
+	start := time.Now()
+	res, err := d.DetectImage(image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("call returned after %v, want at least %v", elapsed, latency)
+	}
+	if res.Duration() != latency {
+		t.Errorf("duration %v, want %v", res.Duration(), latency)
+	}
+}
+
+func TestScorerScript(t *testing.T) {
+	errScore := errors.New("score failed")
+	s := NewScorer(ScoreResponse{Score: 5.5}, ScoreResponse{Score: 3, Err: errScore})
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+
+	if score, err := s.RunImage(img); score != 5.5 || err != nil {
+		t.Errorf("first call: %v, %v, want 5.5, <nil>", score, err)
+	}
+	for i := 0; i < 2; i++ {
+		if score, err := s.RunImage(img); score != 3 || err != errScore {
+			t.Errorf("call %d: %v, %v, want 3, %v", i+2, score, err, errScore)
+		}
+	}
+	if n := len(s.Calls()); n != 3 {
+		t.Errorf("%d calls, want 3", n)
+	}
+
+	if score, err := NewScorer().RunImage(img); score != 0 || err != nil {
+		t.Errorf("empty script: %v, %v, want 0, <nil>", score, err)
+	}
+}
+
+func TestScorerClose(t *testing.T) {
+	s := NewScorer(ScoreResponse{Score: 7})
+	s.Close()
+	if !s.Closed() {
+		t.Fatal("Scorer not closed after Close")
+	}
+	if _, err := s.RunImage(image.NewGray(image.Rect(0, 0, 1, 1))); err != ErrClosed {
+		t.Errorf("error %v, want ErrClosed", err)
+	}
+}
+
+func TestScorerLatency(t *testing.T) {
+	const latency = 20 * time.Millisecond
+	s := NewScorer(ScoreResponse{Score: 1, Latency: latency})
+	start := time.Now()
+	if _, err := s.RunImage(image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("call returned after %v, want at least %v", elapsed, latency)
+	}
+}
+
+func TestFace(t *testing.T) {
+	f := Face(image.Rect(100, 200, 200, 400), 0.8)
+	// box [y1, x1, y2, x2] and landmarks in the detector order: the y coordinates of
+	// the left eye, right eye, nose, left mouth and right mouth, then their x coordinates
+	want := tfimage.NewFace(0.8, [4]float32{200, 100, 400, 200},
+		[10]float32{280, 280, 310, 350, 350, 130, 170, 150, 135, 165})
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("Face %+v, want %+v", f, want)
+	}
+	if w, h := f.Size(); w != 100 || h != 200 {
+		t.Errorf("size %dx%d, want 100x200", w, h)
+	}
+	if x, y := f.LeftEye(); x != 130 || y != 280 {
+		t.Errorf("left eye (%v,%v), want (130,280)", x, y)
+	}
+	if x, y := f.RightEye(); x != 170 || y != 280 {
+		t.Errorf("right eye (%v,%v), want (170,280)", x, y)
+	}
+}
@@ -0,0 +1,329 @@
+//go:build cgo && tflite
+// +build cgo,tflite
+
+package tfimage
+
+/*
+#cgo LDFLAGS: -ltensorflowlite_c
+#include <stdlib.h>
+#include "tensorflow/lite/c/c_api.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// TFLiteBackend runs TensorFlow Lite conversions of the models with the TFLite C API
+// (libtensorflowlite_c 2.11 or later). It is built with the "tflite" build tag.
+//
+// NIMA models are single signature .tflite files with the image as their first input
+// and the scores as their first output. Images are resized bilinearly to the size
+// of the input of the model, usually 224x224.
+//
+// MTCNN models are .tflite files with "pnet", "rnet" and "onet" signatures, each with an
+// "input" of shape [N,H,W,3] and "prob" and "reg" outputs, plus "landmarks" for the
+// O-Net. The cascade between the networks runs in Go, as in the GoBackend.
+// models/convert_tflite.py converts the frozen graphs to these layouts.
+//
+// The "tflite" tag alone also links libtensorflow for the default backend;
+// build with "tflite,notensorflow" to leave it out.
+// Session.IntraOpThreads sets the number of threads of the interpreter.
+var TFLiteBackend Backend = tfliteBackend{}
+
+type tfliteBackend struct{}
+
+func (b tfliteBackend) LoadFaceModel(def []byte, options ModelOptions) (FaceModel, error) {
+	return b.LoadCascadeFaceModel(def, options)
+}
+
+// LoadCascadeFaceModel loads an MTCNN model, the TFLiteBackend always runs the cascade from Go
+func (tfliteBackend) LoadCascadeFaceModel(def []byte, options ModelOptions) (CascadeFaceModel, error) {
+	interp, err := newTFLiteInterpreter(def, options.Session)
+	if err != nil {
+		return nil, err
+	}
+	for _, net := range []string{"pnet", "rnet", "onet"} {
+		if _, err = interp.runner(net); err != nil {
+			interp.close()
+			return nil, fmt.Errorf("mtcnn model: %v", err)
+		}
+	}
+	return &cascadeModel{nets: &tfliteMTCNN{interp: interp}}, nil
+}
+
+func (tfliteBackend) LoadAestheticsModel(def []byte, options ModelOptions) (AestheticsModel, error) {
+	interp, err := newTFLiteInterpreter(def, options.Session)
+	if err != nil {
+		return nil, err
+	}
+	shape := interp.inputShape()
+	if len(shape) != 4 || shape[0] != 1 || shape[1] <= 0 || shape[2] <= 0 || shape[3] != 3 {
+		interp.close()
+		return nil, fmt.Errorf("aesthetics model: input shape %v, expected [1,H,W,3]", shape)
+	}
+	return &tfliteAestheticsModel{interp: interp, height: shape[1], width: shape[2]}, nil
+}
+
+// tfliteInterpreter is a TFLite model and its interpreter. Calls are serialized,
+// since an interpreter runs one inference at a time.
+type tfliteInterpreter struct {
+	mu      sync.Mutex
+	data    unsafe.Pointer // the model buffer, which must outlive the model
+	model   *C.TfLiteModel
+	interp  *C.TfLiteInterpreter
+	runners map[string]*C.TfLiteSignatureRunner
+}
+
+func newTFLiteInterpreter(def []byte, options SessionOptions) (*tfliteInterpreter, error) {
+	if len(def) == 0 {
+		return nil, fmt.Errorf("tflite: empty model")
+	}
+	t := &tfliteInterpreter{data: C.CBytes(def), runners: map[string]*C.TfLiteSignatureRunner{}}
+	t.model = C.TfLiteModelCreate(t.data, C.size_t(len(def)))
+	if t.model == nil {
+		t.close()
+		return nil, fmt.Errorf("tflite: invalid model")
+	}
+	opts := C.TfLiteInterpreterOptionsCreate()
+	defer C.TfLiteInterpreterOptionsDelete(opts)
+	if options.IntraOpThreads > 0 {
+		C.TfLiteInterpreterOptionsSetNumThreads(opts, C.int32_t(options.IntraOpThreads))
+	}
+	t.interp = C.TfLiteInterpreterCreate(t.model, opts)
+	if t.interp == nil {
+		t.close()
+		return nil, fmt.Errorf("tflite: failed to create interpreter")
+	}
+	return t, nil
+}
+
+// runner returns the signature runner of key
+func (t *tfliteInterpreter) runner(key string) (*C.TfLiteSignatureRunner, error) {
+	if r, ok := t.runners[key]; ok {
+		return r, nil
+	}
+	ckey := C.CString(key)
+	defer C.free(unsafe.Pointer(ckey))
+	r := C.TfLiteInterpreterGetSignatureRunner(t.interp, ckey)
+	if r == nil {
+		return nil, fmt.Errorf("tflite: signature %q not found", key)
+	}
+	t.runners[key] = r
+	return r, nil
+}
+
+// runSignature feeds x to the input of signature key and returns the named outputs
+func (t *tfliteInterpreter) runSignature(key, input string, x *goTensor, outputs ...string) ([]*goTensor, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.interp == nil {
+		return nil, errModelClosed
+	}
+	r, err := t.runner(key)
+	if err != nil {
+		return nil, err
+	}
+	cinput := C.CString(input)
+	defer C.free(unsafe.Pointer(cinput))
+	dims := tfliteDims(x.shape)
+	if C.TfLiteSignatureRunnerResizeInputTensor(r, cinput, &dims[0], C.int32_t(len(dims))) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite: %s: failed to resize input %q to %v", key, input, x.shape)
+	}
+	if C.TfLiteSignatureRunnerAllocateTensors(r) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite: %s: failed to allocate tensors", key)
+	}
+	in := C.TfLiteSignatureRunnerGetInputTensor(r, cinput)
+	if in == nil {
+		return nil, fmt.Errorf("tflite: %s: input %q not found", key, input)
+	}
+	if err = tfliteCopyFrom(in, x); err != nil {
+		return nil, err
+	}
+	if C.TfLiteSignatureRunnerInvoke(r) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite: %s: invoke failed", key)
+	}
+	res := make([]*goTensor, len(outputs))
+	for i, name := range outputs {
+		cname := C.CString(name)
+		out := C.TfLiteSignatureRunnerGetOutputTensor(r, cname)
+		C.free(unsafe.Pointer(cname))
+		if out == nil {
+			return nil, fmt.Errorf("tflite: %s: output %q not found", key, name)
+		}
+		if res[i], err = tfliteCopyTo(out); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// inputShape returns the shape of the first input of the model
+func (t *tfliteInterpreter) inputShape() []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.interp == nil || C.TfLiteInterpreterGetInputTensorCount(t.interp) < 1 {
+		return nil
+	}
+	in := C.TfLiteInterpreterGetInputTensor(t.interp, 0)
+	shape := make([]int, int(C.TfLiteTensorNumDims(in)))
+	for i := range shape {
+		shape[i] = int(C.TfLiteTensorDim(in, C.int32_t(i)))
+	}
+	return shape
+}
+
+// run feeds x to the first input of the model and returns its first output
+func (t *tfliteInterpreter) run(x *goTensor) (*goTensor, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.interp == nil {
+		return nil, errModelClosed
+	}
+	dims := tfliteDims(x.shape)
+	if C.TfLiteInterpreterResizeInputTensor(t.interp, 0, &dims[0], C.int32_t(len(dims))) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite: failed to resize input to %v", x.shape)
+	}
+	if C.TfLiteInterpreterAllocateTensors(t.interp) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite: failed to allocate tensors")
+	}
+	if err := tfliteCopyFrom(C.TfLiteInterpreterGetInputTensor(t.interp, 0), x); err != nil {
+		return nil, err
+	}
+	if C.TfLiteInterpreterInvoke(t.interp) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite: invoke failed")
+	}
+	if C.TfLiteInterpreterGetOutputTensorCount(t.interp) < 1 {
+		return nil, fmt.Errorf("tflite: model has no outputs")
+	}
+	return tfliteCopyTo(C.TfLiteInterpreterGetOutputTensor(t.interp, 0))
+}
+
+func (t *tfliteInterpreter) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, r := range t.runners {
+		C.TfLiteSignatureRunnerDelete(r)
+		delete(t.runners, key)
+	}
+	if t.interp != nil {
+		C.TfLiteInterpreterDelete(t.interp)
+		t.interp = nil
+	}
+	if t.model != nil {
+		C.TfLiteModelDelete(t.model)
+		t.model = nil
+	}
+	if t.data != nil {
+		C.free(t.data)
+		t.data = nil
+	}
+}
+
+func tfliteDims(shape []int) []C.int {
+	dims := make([]C.int, len(shape))
+	for i, d := range shape {
+		dims[i] = C.int(d)
+	}
+	return dims
+}
+
+// tfliteCopyFrom copies x to the float32 tensor t
+func tfliteCopyFrom(t *C.TfLiteTensor, x *goTensor) error {
+	if C.TfLiteTensorType(t) != C.kTfLiteFloat32 {
+		return fmt.Errorf("tflite: input tensor is not float32")
+	}
+	if len(x.data) == 0 {
+		return fmt.Errorf("tflite: empty input tensor of shape %v", x.shape)
+	}
+	size := len(x.data) * 4
+	if int(C.TfLiteTensorByteSize(t)) != size {
+		return fmt.Errorf("tflite: input tensor has %d bytes, expected %d", int(C.TfLiteTensorByteSize(t)), size)
+	}
+	if C.TfLiteTensorCopyFromBuffer(t, unsafe.Pointer(&x.data[0]), C.size_t(size)) != C.kTfLiteOk {
+		return fmt.Errorf("tflite: failed to copy input tensor")
+	}
+	return nil
+}
+
+// tfliteCopyTo copies the float32 tensor t to a goTensor
+func tfliteCopyTo(t *C.TfLiteTensor) (*goTensor, error) {
+	if C.TfLiteTensorType(t) != C.kTfLiteFloat32 {
+		return nil, fmt.Errorf("tflite: output tensor is not float32")
+	}
+	shape := make([]int, int(C.TfLiteTensorNumDims(t)))
+	for i := range shape {
+		shape[i] = int(C.TfLiteTensorDim(t, C.int32_t(i)))
+	}
+	x := newGoTensor(shape...)
+	if len(x.data) == 0 {
+		return x, nil
+	}
+	if C.TfLiteTensorCopyToBuffer(t, unsafe.Pointer(&x.data[0]), C.size_t(len(x.data)*4)) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite: failed to copy output tensor")
+	}
+	return x, nil
+}
+
+// tfliteMTCNN runs the MTCNN networks as signatures of a TFLite model
+type tfliteMTCNN struct {
+	interp *tfliteInterpreter
+}
+
+func (m *tfliteMTCNN) pnet(img *goTensor) (prob, reg *goTensor, err error) {
+	res, err := m.interp.runSignature("pnet", "input", img, "prob", "reg")
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0], res[1], nil
+}
+
+func (m *tfliteMTCNN) rnet(crops *goTensor) (prob, reg *goTensor, err error) {
+	res, err := m.interp.runSignature("rnet", "input", crops, "prob", "reg")
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0], res[1], nil
+}
+
+func (m *tfliteMTCNN) onet(crops *goTensor) (prob, reg, landmarks *goTensor, err error) {
+	res, err := m.interp.runSignature("onet", "input", crops, "prob", "reg", "landmarks")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return res[0], res[1], res[2], nil
+}
+
+func (m *tfliteMTCNN) close() {
+	m.interp.close()
+}
+
+// tfliteAestheticsModel is a NIMA model of the TFLiteBackend, with the input size of its model
+type tfliteAestheticsModel struct {
+	interp        *tfliteInterpreter
+	height, width int
+}
+
+func (m *tfliteAestheticsModel) Scores(img *ImageData) ([]float32, error) {
+	if err := img.check(); err != nil {
+		return nil, err
+	}
+	x := &goTensor{shape: []int{1, img.Height, img.Width, 3}, data: img.Pix}
+	if img.Height != m.height || img.Width != m.width {
+		var err error
+		if x, err = resizeBilinear(x, m.height, m.width); err != nil {
+			return nil, fmt.Errorf("aesthetics model: %v", err)
+		}
+	}
+	out, err := m.interp.run(x)
+	if err != nil {
+		return nil, fmt.Errorf("aesthetics model: %v", err)
+	}
+	return out.data, nil
+}
+
+func (m *tfliteAestheticsModel) Close() {
+	m.interp.close()
+}
@@ -0,0 +1,129 @@
+//go:build cgo && tflite && !notensorflow
+// +build cgo,tflite,!notensorflow
+
+package tfimage
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// The parity test compares the TFLiteBackend against the TensorflowBackend, so it
+// needs both libraries: go test -tags tflite. The .tflite models are made with
+// models/convert_tflite.py, and the test is skipped when they are missing.
+
+// Tolerances of the TFLiteBackend against the TensorflowBackend
+const (
+	tfliteMinIoU        = 0.95
+	tfliteMaxProbDelta  = 0.01
+	tfliteMaxScoreDelta = 0.05 // NIMA mean score, from 1 to 10
+)
+
+// readOptionalModel reads a model file of the models directory, or skips the test
+func readOptionalModel(t *testing.T, name string) []byte {
+	t.Helper()
+	def, err := os.ReadFile(filepath.Join("models", name))
+	if os.IsNotExist(err) {
+		t.Skipf("models/%s not found, see models/convert_tflite.py", name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return def
+}
+
+func TestTFLiteMTCNNParity(t *testing.T) {
+	lite, err := NewFaceDetectorFromBytes(readOptionalModel(t, "mtcnn_1.14.tflite"),
+		FaceDetectorOptions{ModelOptions: ModelOptions{Backend: TFLiteBackend}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lite.Close()
+	ref, err := NewFaceDetectorFromBytes(readModel(t, "mtcnn_1.14.pb"), FaceDetectorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ref.Close()
+
+	for name, img := range testImages(t) {
+		tensor, err := NewImageData(img).tensor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ref.DetectFaces(tensor)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := lite.DetectFaces(tensor)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		compareFaces(t, name, want.results, got.results, tfliteMinIoU, tfliteMaxProbDelta)
+	}
+}
+
+func TestTFLiteNIMAParity(t *testing.T) {
+	lite, err := NewAestheticsEvaluatorFromBytes(readOptionalModel(t, "nima_1.14.tflite"),
+		AestheticsOptions{ModelOptions: ModelOptions{Backend: TFLiteBackend}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lite.Close()
+	ref, err := NewAestheticsEvaluatorFromBytes(readOptionalModel(t, "nima_1.14.pb"), AestheticsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ref.Close()
+
+	for name, img := range testImages(t) {
+		// compare at the 224x224 input size of the converted NIMA model
+		img = imaging.Resize(img, 224, 224, imaging.Linear)
+		want, err := ref.RunImage(img)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := lite.RunImage(img)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if d := math.Abs(float64(got - want)); d > tfliteMaxScoreDelta {
+			t.Errorf("%s: score %.4f, want %.4f ± %.2f", name, got, want, tfliteMaxScoreDelta)
+		}
+	}
+}
+
+func TestTFLiteNIMAInputSize(t *testing.T) {
+	lite, err := NewAestheticsEvaluatorFromBytes(readOptionalModel(t, "nima_1.14.tflite"),
+		AestheticsOptions{ModelOptions: ModelOptions{Backend: TFLiteBackend}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lite.Close()
+
+	// images of other sizes are resized bilinearly to the 224x224 input
+	img := testImages(t)["grace_hopper"]
+	got, err := lite.RunImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := NewImageData(img)
+	resized, err := resizeBilinear(&goTensor{shape: []int{1, data.Height, data.Width, 3}, data: data.Pix}, 224, 224)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := lite.model.Scores(&ImageData{Width: 224, Height: 224, Pix: resized.data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := calcScore(values); got != want {
+		t.Errorf("score of the %v image %v, want %v", img.Bounds().Size(), got, want)
+	}
+
+	if _, err = lite.model.Scores(&ImageData{}); err == nil {
+		t.Error("empty image: expected an error")
+	}
+}
@@ -1,51 +1,142 @@
 package tfimage
 
 import (
+	"fmt"
 	"image"
-	"image/jpeg"
-	"os"
+	"image/color"
+	"math"
+	"strings"
 
 	"github.com/evanoberholster/gg"
 )
 
 // SaveJPG - Save image to JPG
 func SaveJPG(path string, im image.Image, quality int) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	return SaveImage(path, im, JPEGEncoder{Quality: quality})
+}
+
+// RenderOptions - Options of FaceResults.Render. The zero value renders like DrawDebugJPEG.
+type RenderOptions struct {
+	// BoxColor defaults to a translucent dark red, LandmarkColor to a translucent dark green
+	BoxColor, LandmarkColor color.Color
+	// StrokeWidth outlines the boxes with lines of that width. Zero fills the boxes.
+	StrokeWidth float64
+	// LandmarkRadius is the radius of the landmark points, defaults to 10
+	LandmarkRadius float64
 
-	return jpeg.Encode(file, im, &jpeg.Options{Quality: quality})
+	// ShowIndex, ShowProbability and ShowRoll label each face with its index,
+	// its probability and the roll angle of its eyes in degrees, zero for level eyes
+	ShowIndex, ShowProbability, ShowRoll bool
+	// LabelColor is the color of the labels, defaults to white
+	LabelColor color.Color
+
+	// AlignRegion outlines the region of the aligned crop of each face, if set
+	AlignRegion *AlignOptions
+	// AlignColor defaults to a translucent blue
+	AlignColor color.Color
 }
 
-func (fr *FaceResults) DrawDebugJPEG(path string, src image.Image) error {
+// Default RenderOptions colors
+var (
+	renderBoxColor      = color.NRGBA{106, 0, 0, 76}
+	renderLandmarkColor = color.NRGBA{0, 106, 0, 178}
+	renderAlignColor    = color.NRGBA{0, 0, 255, 178}
+)
+
+// Render draws the boxes, landmarks and labels of the faces over src and returns the image
+func (fr *FaceResults) Render(src image.Image, opts RenderOptions) image.Image {
+	if opts.BoxColor == nil {
+		opts.BoxColor = renderBoxColor
+	}
+	if opts.LandmarkColor == nil {
+		opts.LandmarkColor = renderLandmarkColor
+	}
+	if opts.LandmarkRadius == 0 {
+		opts.LandmarkRadius = 10
+	}
+	if opts.LabelColor == nil {
+		opts.LabelColor = color.White
+	}
+	if opts.AlignColor == nil {
+		opts.AlignColor = renderAlignColor
+	}
+
 	ctx := gg.NewContextForImage(src)
-	for _, f := range fr.results {
+	for i, f := range fr.results {
 		// Draw Face
 		ctx.Push()
 		ctx.DrawRectangle(float64(f.box[1]), float64(f.box[0]), float64(f.box[3]-f.box[1]), float64(f.box[2]-f.box[0]))
-		ctx.SetRGBA(150, 0, 0, 0.3)
-		ctx.Fill()
+		ctx.SetColor(opts.BoxColor)
+		if opts.StrokeWidth > 0 {
+			ctx.SetLineWidth(opts.StrokeWidth)
+			ctx.Stroke()
+		} else {
+			ctx.Fill()
+		}
 		ctx.Pop()
 
 		// Draw Spots
 		ctx.Push()
 		x, y := f.LeftEye()
-		ctx.DrawPoint(x, y, 10)
+		ctx.DrawPoint(x, y, opts.LandmarkRadius)
 		x, y = f.RightEye()
-		ctx.DrawPoint(x, y, 10)
+		ctx.DrawPoint(x, y, opts.LandmarkRadius)
 		x, y = f.LeftMouth()
-		ctx.DrawPoint(x, y, 10)
+		ctx.DrawPoint(x, y, opts.LandmarkRadius)
 		x, y = f.RightMouth()
-		ctx.DrawPoint(x, y, 10)
+		ctx.DrawPoint(x, y, opts.LandmarkRadius)
 		x, y = f.Nose()
-		ctx.DrawPoint(x, y, 10)
+		ctx.DrawPoint(x, y, opts.LandmarkRadius)
 		x, y = f.EyesCenter()
-		ctx.DrawPoint(x, y, 10)
-		ctx.SetRGBA(0, 150, 0, 0.7)
+		ctx.DrawPoint(x, y, opts.LandmarkRadius)
+		ctx.SetColor(opts.LandmarkColor)
 		ctx.Fill()
 		ctx.Pop()
+
+		// Draw the aligned crop region
+		if opts.AlignRegion != nil {
+			if inv, err := f.AlignMatrix(*opts.AlignRegion).Invert(); err == nil {
+				ctx.Push()
+				w, h := float64(opts.AlignRegion.Width), float64(opts.AlignRegion.Height)
+				for j, p := range [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+					x, y := inv.TransformPoint(p[0], p[1])
+					if j == 0 {
+						ctx.MoveTo(x, y)
+					} else {
+						ctx.LineTo(x, y)
+					}
+				}
+				ctx.ClosePath()
+				ctx.SetColor(opts.AlignColor)
+				ctx.SetLineWidth(math.Max(opts.StrokeWidth, 2))
+				ctx.Stroke()
+				ctx.Pop()
+			}
+		}
+
+		// Draw Labels
+		var labels []string
+		if opts.ShowIndex {
+			labels = append(labels, fmt.Sprintf("#%d", i))
+		}
+		if opts.ShowProbability {
+			labels = append(labels, fmt.Sprintf("%.1f%%", f.p*100))
+		}
+		if opts.ShowRoll {
+			// Angle is the direction from the right eye to the left eye
+			labels = append(labels, fmt.Sprintf("roll %.1f", math.Remainder(f.Angle()*180/math.Pi-180, 360)))
+		}
+		if len(labels) > 0 {
+			ctx.Push()
+			ctx.SetColor(opts.LabelColor)
+			ctx.DrawStringAnchored(strings.Join(labels, " "), float64(f.box[1]), float64(f.box[0])-4, 0, 0)
+			ctx.Pop()
+		}
 	}
-	return SaveJPG(path, ctx.Image(), 70)
+	return ctx.Image()
+}
+
+// DrawDebugJPEG renders the faces over src with the default RenderOptions and saves it as a JPEG at path
+func (fr *FaceResults) DrawDebugJPEG(path string, src image.Image) error {
+	return SaveJPG(path, fr.Render(src, RenderOptions{}), 70)
 }
@@ -0,0 +1,89 @@
+package tfimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/evanoberholster/gg"
+	"golang.org/x/image/draw"
+)
+
+// baselineDebugImage draws the faces as DrawDebugJPEG did before Render
+func baselineDebugImage(fr *FaceResults, src image.Image) image.Image {
+	ctx := gg.NewContextForImage(src)
+	for _, f := range fr.results {
+		ctx.Push()
+		ctx.DrawRectangle(float64(f.box[1]), float64(f.box[0]), float64(f.box[3]-f.box[1]), float64(f.box[2]-f.box[0]))
+		ctx.SetRGBA(150, 0, 0, 0.3)
+		ctx.Fill()
+		ctx.Pop()
+
+		ctx.Push()
+		for _, p := range []func() (float64, float64){f.LeftEye, f.RightEye, f.LeftMouth, f.RightMouth, f.Nose, f.EyesCenter} {
+			x, y := p()
+			ctx.DrawPoint(x, y, 10)
+		}
+		ctx.SetRGBA(0, 150, 0, 0.7)
+		ctx.Fill()
+		ctx.Pop()
+	}
+	return ctx.Image()
+}
+
+func renderTestSource() *image.RGBA {
+	src := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	draw.Draw(src, src.Rect, image.NewUniform(color.RGBA{200, 210, 220, 255}), image.Point{}, draw.Src)
+	return src
+}
+
+func TestRenderDefaultColors(t *testing.T) {
+	src := renderTestSource()
+	fr := NewFaceResults([]Face{
+		NewFace(0.99, [4]float32{40, 30, 160, 130}, [10]float32{80, 80, 120, 120, 100, 60, 100, 80, 65, 95}),
+		NewFace(0.9, [4]float32{60, 180, 200, 300}, [10]float32{110, 120, 140, 150, 160, 210, 270, 240, 215, 265}),
+	}, 0)
+
+	want := baselineDebugImage(fr, src)
+	if got := fr.Render(src, RenderOptions{}); !reflect.DeepEqual(got, want) {
+		t.Error("Render with the zero RenderOptions differs from the baseline debug image")
+	}
+
+	dir := t.TempDir()
+	path, wantPath := filepath.Join(dir, "debug.jpg"), filepath.Join(dir, "want.jpg")
+	if err := fr.DrawDebugJPEG(path, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveJPG(wantPath, want, 70); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData, err := ioutil.ReadFile(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, wantData) {
+		t.Error("DrawDebugJPEG differs from the baseline debug image")
+	}
+}
+
+func TestRenderRollLabel(t *testing.T) {
+	src := renderTestSource()
+	// the right eye is 30 degrees below the left eye, 40 pixels away
+	f := NewFace(0.99, [4]float32{60, 80, 200, 200}, [10]float32{100, 120, 150, 170, 170, 100, 134.641016, 130, 105, 150})
+	fr := NewFaceResults([]Face{f}, 0)
+
+	ctx := gg.NewContextForImage(fr.Render(src, RenderOptions{}))
+	ctx.SetColor(color.White)
+	ctx.DrawStringAnchored("roll 30.0", 80, 56, 0, 0)
+	if got := fr.Render(src, RenderOptions{ShowRoll: true}); !reflect.DeepEqual(got, ctx.Image()) {
+		t.Error("Render with ShowRoll differs from the roll label of 30 degrees")
+	}
+}